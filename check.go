@@ -0,0 +1,307 @@
+package gnome
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nullmonk/gnome/modules"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Problem is an issue found by Check
+type Problem struct {
+	Pos syntax.Position
+	Msg string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Pos, p.Msg)
+}
+
+// Check finds problems in scripts without running them: syntax errors, undefined names, calls to
+// functions that do not exist or are not implemented, and calls with the wrong number of arguments.
+// The asset scripts are checked first, then the given scripts, and the globals of each script are
+// defined for the scripts after it, as they would be by Run
+func (i *Interpreter) Check(scripts []string) ([]Problem, error) {
+	toCheck, err := i.assetScripts()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scripts {
+		toCheck = append(toCheck, diskScript(s))
+	}
+
+	globals := make(map[string]bool)
+	problems := make([]Problem, 0)
+	for _, s := range toCheck {
+		src, ok := s.src.([]byte)
+		if !ok && s.compiled != nil {
+			// Compiled scripts were checked when they were compiled, the globals they define are
+			// kept with the bytecode
+			if h, _, err := decodeCompiled(s.compiled); err == nil && !i.Options.ExplicitExports {
+				for _, g := range h.Globals {
+					globals[g] = true
+				}
+			}
+			continue
+		} else if !ok {
+			if src, err = os.ReadFile(s.name); err != nil {
+				problems = append(problems, Problem{syntax.MakePosition(&s.name, 0, 0), err.Error()})
+				continue
+			}
+		}
+		p, defined := i.checkScript(s.name, src, globals)
+		problems = append(problems, p...)
+		if !i.Options.ExplicitExports {
+			for _, g := range defined {
+				globals[g] = true
+			}
+		}
+	}
+	return problems, nil
+}
+
+// checkScript checks a single script, returning its problems and the globals it defines
+func (i *Interpreter) checkScript(name string, src []byte, globals map[string]bool) ([]Problem, []string) {
+	f, err := fileOptions.Parse(name, src, 0)
+	if err != nil {
+		if e, ok := err.(syntax.Error); ok {
+			return []Problem{{e.Pos, e.Msg}}, nil
+		}
+		return []Problem{{syntax.MakePosition(&name, 0, 0), err.Error()}}, nil
+	}
+
+	libs := i.modules
+	if strings.HasSuffix(name, TestSuffix) {
+		libs = i.testPredeclared()
+	}
+	problems := make([]Problem, 0)
+	isPredeclared := func(n string) bool {
+		return libs.Has(n) || n == sharedName || globals[n]
+	}
+	if err := resolve.File(f, isPredeclared, starlark.Universe.Has); err != nil {
+		if list, ok := err.(resolve.ErrorList); ok {
+			for _, e := range list {
+				problems = append(problems, Problem{e.Pos, e.Msg})
+			}
+		} else {
+			problems = append(problems, Problem{syntax.MakePosition(&name, 0, 0), err.Error()})
+		}
+	}
+
+	walk(f, func(n syntax.Node) bool {
+		if call, ok := n.(*syntax.CallExpr); ok {
+			if p := checkCall(libs, call); p != nil {
+				problems = append(problems, *p)
+			}
+		}
+		return true
+	})
+
+	sort.SliceStable(problems, func(a, b int) bool {
+		pa, pb := problems[a].Pos, problems[b].Pos
+		return pa.Line < pb.Line || (pa.Line == pb.Line && pa.Col < pb.Col)
+	})
+
+	defined := make([]string, 0)
+	if m, ok := f.Module.(*resolve.Module); ok {
+		for _, b := range m.Globals {
+			if !strings.HasPrefix(b.First.Name, "_") {
+				defined = append(defined, b.First.Name)
+			}
+		}
+	}
+	return problems, defined
+}
+
+// predeclared returns the gnome module or builtin an identifier refers to, if it refers to one
+func predeclared(libs starlark.StringDict, id *syntax.Ident) starlark.Value {
+	if b, ok := id.Binding.(*resolve.Binding); !ok || b.Scope != resolve.Predeclared {
+		return nil
+	}
+	return libs[id.Name]
+}
+
+// checkCall checks calls to module functions and gnome builtins
+func checkCall(libs starlark.StringDict, call *syntax.CallExpr) *Problem {
+	var b *modules.Builtin
+	switch fn := call.Fn.(type) {
+	case *syntax.Ident:
+		var ok bool
+		if b, ok = predeclared(libs, fn).(*modules.Builtin); !ok {
+			return nil
+		}
+	case *syntax.DotExpr:
+		id, ok := fn.X.(*syntax.Ident)
+		if !ok {
+			return nil
+		}
+		var m modules.Module
+		switch v := predeclared(libs, id).(type) {
+		case modules.Module:
+			m = v
+		case *modules.Module:
+			m = *v
+		default:
+			return nil
+		}
+		pos, _ := fn.Name.Span()
+		if b, ok = m[fn.Name.Name].(*modules.Builtin); !ok {
+			return &Problem{pos, fmt.Sprintf("%s has no function %s", id.Name, fn.Name.Name)}
+		}
+		if !b.Implemented() {
+			return &Problem{pos, fmt.Sprintf("%s is not implemented", b.Name())}
+		}
+	default:
+		return nil
+	}
+	return checkArgs(call, b)
+}
+
+// checkArgs compares the arguments of a call to the parameters of the function
+func checkArgs(call *syntax.CallExpr, b *modules.Builtin) *Problem {
+	if b.Variadic {
+		return nil
+	}
+	pos, _ := call.Span()
+	bound := make([]bool, len(b.Params))
+	positional := 0
+	for _, arg := range call.Args {
+		if a, ok := arg.(*syntax.UnaryExpr); ok && (a.Op == syntax.STAR || a.Op == syntax.STARSTAR) {
+			// The arguments are only known at runtime
+			return nil
+		}
+		if a, ok := arg.(*syntax.BinaryExpr); ok && a.Op == syntax.EQ {
+			name := a.X.(*syntax.Ident).Name
+			i := paramIndex(b, name)
+			if i < 0 {
+				return &Problem{pos, fmt.Sprintf("%s has no parameter %s", b.Name(), name)}
+			}
+			if bound[i] {
+				return &Problem{pos, fmt.Sprintf("%s got multiple values for parameter %s", b.Name(), name)}
+			}
+			bound[i] = true
+			continue
+		}
+		if positional < len(bound) {
+			bound[positional] = true
+		}
+		positional++
+	}
+
+	if positional > len(b.Params) {
+		return &Problem{pos, fmt.Sprintf("%s takes at most %d arguments, got %d", b.Name(), len(b.Params), positional)}
+	}
+	for i, p := range b.Params {
+		if !bound[i] && p.Default == nil {
+			return &Problem{pos, fmt.Sprintf("%s is missing argument %s", b.Name(), p.Name)}
+		}
+	}
+	return nil
+}
+
+// paramIndex returns the index of the named parameter of b, or -1
+func paramIndex(b *modules.Builtin, name string) int {
+	for i, p := range b.Params {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// walk is syntax.Walk, which does not know the while loops that fileOptions allow
+func walk(n syntax.Node, f func(syntax.Node) bool) {
+	if !f(n) {
+		return
+	}
+	walkAll := func(nodes ...syntax.Node) {
+		for _, n := range nodes {
+			if n != nil {
+				walk(n, f)
+			}
+		}
+	}
+	walkStmts := func(stmts []syntax.Stmt) {
+		for _, stmt := range stmts {
+			walk(stmt, f)
+		}
+	}
+	walkExprs := func(exprs []syntax.Expr) {
+		for _, x := range exprs {
+			walk(x, f)
+		}
+	}
+
+	switch n := n.(type) {
+	case *syntax.File:
+		walkStmts(n.Stmts)
+	case *syntax.ExprStmt:
+		walkAll(n.X)
+	case *syntax.IfStmt:
+		walkAll(n.Cond)
+		walkStmts(n.True)
+		walkStmts(n.False)
+	case *syntax.WhileStmt:
+		walkAll(n.Cond)
+		walkStmts(n.Body)
+	case *syntax.ForStmt:
+		walkAll(n.Vars, n.X)
+		walkStmts(n.Body)
+	case *syntax.AssignStmt:
+		walkAll(n.LHS, n.RHS)
+	case *syntax.DefStmt:
+		walkAll(n.Name)
+		walkExprs(n.Params)
+		walkStmts(n.Body)
+	case *syntax.ReturnStmt:
+		walkAll(n.Result)
+	case *syntax.LoadStmt:
+		walkAll(n.Module)
+		for i := range n.From {
+			walkAll(n.From[i], n.To[i])
+		}
+	case *syntax.ListExpr:
+		walkExprs(n.List)
+	case *syntax.TupleExpr:
+		walkExprs(n.List)
+	case *syntax.DictExpr:
+		walkExprs(n.List)
+	case *syntax.DictEntry:
+		walkAll(n.Key, n.Value)
+	case *syntax.ParenExpr:
+		walkAll(n.X)
+	case *syntax.CondExpr:
+		walkAll(n.Cond, n.True, n.False)
+	case *syntax.IndexExpr:
+		walkAll(n.X, n.Y)
+	case *syntax.SliceExpr:
+		walkAll(n.X, n.Lo, n.Hi, n.Step)
+	case *syntax.Comprehension:
+		walkAll(n.Body)
+		for _, clause := range n.Clauses {
+			walk(clause, f)
+		}
+	case *syntax.IfClause:
+		walkAll(n.Cond)
+	case *syntax.ForClause:
+		walkAll(n.Vars, n.X)
+	case *syntax.UnaryExpr:
+		walkAll(n.X)
+	case *syntax.BinaryExpr:
+		walkAll(n.X, n.Y)
+	case *syntax.DotExpr:
+		walkAll(n.X, n.Name)
+	case *syntax.CallExpr:
+		walkAll(n.Fn)
+		walkExprs(n.Args)
+	case *syntax.LambdaExpr:
+		walkExprs(n.Params)
+		walkAll(n.Body)
+	}
+	f(nil)
+}
@@ -0,0 +1,68 @@
+package gnome
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"clean", "x = file.read('/etc/hosts')\n", nil},
+		{"while", "while True:\n    file.read()\n", []string{"file.read is missing argument path"}},
+		{"while in def", "def f():\n    while x:\n        file.nope()\n", []string{"undefined: x", "file has no function nope"}},
+		{"unknown keyword", "file.read('/', mode='r')\n", []string{"file.read has no parameter mode"}},
+		{"too many", "file.read('/', '/')\n", []string{"file.read takes at most 1 arguments, got 2"}},
+	}
+	interp := NewInterpreter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "script.eldr")
+			if err := os.WriteFile(name, []byte(tt.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+			problems, err := interp.Check([]string{name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.Msg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCompiled(t *testing.T) {
+	interp := NewInterpreter()
+	dir := t.TempDir()
+	compiled, _, err := interp.Compile("first.eldr", []byte("greeting = 'hello'\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the compiled form of the first script is there
+	first := filepath.Join(dir, "first"+CompiledExt)
+	second := filepath.Join(dir, "second.eldr")
+	if err := os.WriteFile(first, compiled, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("print(greeting)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := interp.Check([]string{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("Check() = %v, want no problems", problems)
+	}
+}
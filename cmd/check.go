@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// checkMain statically checks the assets and the scripts given on the command line
+func checkMain(args []string) {
+	var opts options
+	flags := flag.NewFlagSet("gnome check", flag.ExitOnError)
+	opts.register(flags)
+	explicit := flags.Bool("explicit-exports", false, "only share values passed to export() between scripts")
+	flags.Parse(args)
+
+	interp := opts.interpreter()
+	interp.Options.ExplicitExports = *explicit
+	problems, err := interp.Check(flags.Args())
+	if err != nil {
+		fmt.Printf("[!] %s\n", err)
+		os.Exit(1)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nullmonk/gnome"
+)
+
+// compileMain compiles scripts to bytecode. Scripts are compiled in the order given, and the
+// globals of each script are available to the scripts after it, like they are in a run
+func compileMain(args []string) {
+	var opts options
+	flags := flag.NewFlagSet("gnome compile", flag.ExitOnError)
+	opts.register(flags)
+	out := flags.String("o", "", "`directory` to write the compiled scripts to, defaults to next to each script")
+	flags.Parse(args)
+
+	interp := opts.interpreter()
+	globals := make(map[string]bool)
+	failed := false
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Printf("[!] %s\n", err)
+			failed = true
+			continue
+		}
+		compiled, defined, err := interp.Compile(name, src, globals)
+		if err != nil {
+			fmt.Printf("[!] error compiling '%s': %s\n", name, err)
+			failed = true
+			continue
+		}
+		for _, g := range defined {
+			globals[g] = true
+		}
+
+		dst := gnome.CompiledName(name)
+		if *out != "" {
+			dst = filepath.Join(*out, filepath.Base(dst))
+		}
+		if err := os.WriteFile(dst, compiled, 0644); err != nil {
+			fmt.Printf("[!] %s\n", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("[+] compiled '%s' to '%s'\n", name, dst)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// docsMain writes the markdown reference of the modules
+func docsMain(args []string) {
+	var opts options
+	flags := flag.NewFlagSet("gnome docs", flag.ExitOnError)
+	opts.register(flags)
+	out := flags.String("o", "", "`file` to write the reference to instead of stdout")
+	flags.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Printf("[!] %s\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := opts.interpreter().WriteDocs(w); err != nil {
+		fmt.Printf("[!] %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,22 +1,197 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/nullmonk/gnome"
+	"github.com/nullmonk/gnome/modules"
+	"go.starlark.net/starlark"
 )
 
 //go:embed example
 var assets embed.FS
 
-func main() {
+// options are the flags shared by all the commands
+type options struct {
+	policy   string
+	trace    bool
+	record   string
+	replay   string
+	dryRun   bool
+	readOnly bool
+	root     string
+	redact   bool
+}
+
+func (o *options) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.policy, "policy", "", "JSON `file` with the policy of functions scripts may call")
+	flags.BoolVar(&o.trace, "trace", false, "print every module function called and how long it took")
+	flags.StringVar(&o.record, "record", "", "record every module function call and its result to a JSONL cassette `file`")
+	flags.BoolVar(&o.dryRun, "dry-run", false, "print the changes scripts would make to the system instead of making them")
+	flags.BoolVar(&o.redact, "redact", false, "hide the arguments of function calls in error reports and traces")
+	flags.StringVar(&o.root, "root", "", "resolve the paths used by the file module inside `dir`, such as an extracted image")
+	flags.BoolVar(&o.readOnly, "read-only", false, "fail every change the file module would make to the filesystem")
+	flags.StringVar(&o.replay, "replay", "", "answer module function calls from a cassette `file` instead of running them")
+}
+
+// interpreter creates an interpreter with the example assets and the options applied
+func (o *options) interpreter() *gnome.Interpreter {
 	assets, _ := fs.Sub(assets, "example") // strip "example/" from the embedded asset names
-	gnome.SetAssetLocker(assets)           // register the assets
-	gnome.Run(os.Args[1:], func(script string, err error) error {
-		fmt.Printf("[!] error executing '%s': %s\n", script, err)
+	interp := gnome.NewInterpreter()
+	interp.SetAssetLocker(assets) // register the assets
+	if o.policy != "" {
+		p, err := modules.LoadPolicy(o.policy)
+		if err != nil {
+			fmt.Printf("[!] %s\n", err)
+			os.Exit(1)
+		}
+		interp.Policy = p
+	}
+	interp.Options.DryRun = o.dryRun
+	var fsys modules.FS = modules.OSFS{}
+	if o.root != "" {
+		root, err := modules.NewRootFS(o.root)
+		if err != nil {
+			fmt.Printf("[!] %s\n", err)
+			os.Exit(1)
+		}
+		fsys = root
+	}
+	if o.readOnly {
+		fsys = modules.ReadOnly(fsys)
+	}
+	interp.FS = fsys
+	if o.trace {
+		interp.Interceptors = append(interp.Interceptors, o.traceCall)
+	}
+	if o.record != "" {
+		if o.dryRun {
+			fmt.Println("[!] -record cannot be used with -dry-run, the results would not be real")
+			os.Exit(1)
+		}
+		f, err := os.Create(o.record)
+		if err != nil {
+			fmt.Printf("[!] %s\n", err)
+			os.Exit(1)
+		}
+		interp.Interceptors = append(interp.Interceptors, modules.NewRecorder(f).Intercept)
+	}
+	if o.replay != "" {
+		r, err := modules.LoadCassette(o.replay)
+		if err != nil {
+			fmt.Printf("[!] %s\n", err)
+			os.Exit(1)
+		}
+		interp.Interceptors = append(interp.Interceptors, r.Intercept)
+	}
+	return interp
+}
+
+// errorReport describes the error of a script, with the details indented under its message
+func (o *options) errorReport(interp *gnome.Interpreter, err error) string {
+	msg, details, _ := strings.Cut(interp.FormatError(err, o.redact), "\n")
+	if details == "" {
+		return msg + "\n"
+	}
+	return msg + "\n" + indent(details)
+}
+
+// redactError replaces an error with its redacted report for the JSON output, returning the
+// details of the report to stand in for the backtrace
+func redactError(interp *gnome.Interpreter, err error) (error, string) {
+	msg, details, _ := strings.Cut(interp.FormatError(err, true), "\n")
+	return errors.New(msg), details
+}
+
+// traceCall prints each call with its arguments, duration and error
+func (o *options) traceCall(call *modules.Call, next modules.Handler) (starlark.Value, error) {
+	start := time.Now()
+	v, err := next(call)
+	msg := fmt.Sprintf("[trace] %s: %s %s", call.Thread.Name, modules.FormatCall(call.Name(), call.Args, call.Kwargs, o.redact), time.Since(start))
+	if err != nil && o.redact {
+		msg += ": " + modules.RedactMessage(err.Error(), call.Args, call.Kwargs)
+	} else if err != nil {
+		msg += ": " + err.Error()
+	}
+	fmt.Fprintln(os.Stderr, msg)
+	return v, err
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "repl":
+			replMain(os.Args[2:])
+			return
+		case "compile":
+			compileMain(os.Args[2:])
+			return
+		case "check":
+			checkMain(os.Args[2:])
+			return
+		case "docs":
+			docsMain(os.Args[2:])
+			return
+		case "test":
+			testMain(os.Args[2:])
+			return
+		}
+	}
+	runMain(os.Args[1:])
+}
+
+// runMain runs the assets and the scripts given on the command line
+func runMain(args []string) {
+	var opts options
+	flags := flag.NewFlagSet("gnome", flag.ExitOnError)
+	opts.register(flags)
+	asJson := flags.Bool("json", false, "print the results of the scripts as JSON")
+	parallel := flags.Bool("parallel", false, "run scripts without dependencies concurrently")
+	workers := flags.Int("workers", 0, "maximum `number` of scripts to run at once with -parallel")
+	explicit := flags.Bool("explicit-exports", false, "only share values passed to export() between scripts")
+	flags.Parse(args)
+
+	interp := opts.interpreter()
+	interp.Options.Parallel = *parallel
+	interp.Options.Workers = *workers
+	interp.Options.ExplicitExports = *explicit
+	results, err := interp.Run(context.Background(), flags.Args(), func(script string, err error) error {
+		if !*asJson {
+			fmt.Printf("[!] error executing '%s': %s", script, opts.errorReport(interp, err))
+		}
 		return nil
 	})
+	if *asJson {
+		for k, r := range results {
+			if r.Err != nil && opts.redact {
+				results[k].Err, results[k].Backtrace = redactError(interp, r.Err)
+			}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		for _, r := range results {
+			if r.Skipped != "" {
+				fmt.Printf("[*] skipped '%s': %s\n", r.Name, r.Skipped)
+			}
+			for _, w := range r.Warnings {
+				fmt.Printf("[*] warning in '%s': %s\n", r.Name, w)
+			}
+		}
+	}
+
+	var exitErr *gnome.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/chzyer/readline"
+	"github.com/nullmonk/gnome"
+	"github.com/nullmonk/gnome/modules"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// replOptions match the options scripts are run with, load() binds globally like it does in python
+var replOptions = &syntax.FileOptions{
+	Set:               true,
+	While:             true,
+	TopLevelControl:   true,
+	GlobalReassign:    true,
+	LoadBindsGlobally: true,
+}
+
+// replMain starts an interactive session with all the modules and assets loaded
+func replMain(args []string) {
+	var opts options
+	flags := flag.NewFlagSet("gnome repl", flag.ExitOnError)
+	opts.register(flags)
+	flags.Parse(args)
+
+	interp := opts.interpreter()
+	interp.Output = os.Stdout
+	globals := interp.Predeclared()
+
+	history := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		history = filepath.Join(home, ".gnome_history")
+	}
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       ">>> ",
+		HistoryFile:  history,
+		AutoComplete: &completer{globals},
+	})
+	if err != nil {
+		fmt.Printf("[!] %s\n", err)
+		os.Exit(1)
+	}
+	defer rl.Close()
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+
+	for {
+		err := rep(interp, &opts, rl, globals, interrupted)
+		if err == io.EOF {
+			break
+		}
+		var exitErr *gnome.ExitError
+		if errors.As(err, &exitErr) {
+			rl.Close()
+			os.Exit(exitErr.Code)
+		}
+	}
+	fmt.Println()
+}
+
+// rep reads, evaluates and prints a single statement, which may span several lines
+func rep(interp *gnome.Interpreter, opts *options, rl *readline.Instance, globals starlark.StringDict, interrupted chan os.Signal) error {
+	eof := false
+	rl.SetPrompt(">>> ")
+	readLine := func() ([]byte, error) {
+		line, err := rl.Readline()
+		rl.SetPrompt("... ")
+		if err != nil {
+			if err == io.EOF {
+				eof = true
+			}
+			return nil, err
+		}
+		return []byte(line + "\n"), nil
+	}
+
+	f, err := replOptions.ParseCompoundStmt("<stdin>", readLine)
+	if err != nil {
+		if eof {
+			return io.EOF
+		}
+		if err != readline.ErrInterrupt {
+			fmt.Println(err)
+		}
+		return nil
+	}
+
+	// Each statement gets its own thread, so an interrupt or quit() only stops that statement
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupted:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	thread := interp.NewThread(ctx, "<stdin>")
+	stop := context.AfterFunc(ctx, func() {
+		thread.Cancel("interrupted")
+	})
+	defer stop()
+
+	if expr := soleExpr(f); expr != nil {
+		v, err := starlark.EvalExprOptions(f.Options, thread, expr, globals)
+		if err == nil && v != starlark.None {
+			fmt.Println(pretty(v))
+		}
+		return report(interp, opts, thread, err)
+	}
+	return report(interp, opts, thread, starlark.ExecREPLChunk(f, thread, globals))
+}
+
+// report prints an error from the statement. exit() is returned to stop the REPL
+func report(interp *gnome.Interpreter, opts *options, thread *starlark.Thread, err error) error {
+	if h := gnome.Halted(thread); h != nil {
+		if h == gnome.ErrQuit {
+			return nil
+		}
+		return h
+	}
+	if err == nil {
+		return nil
+	}
+	fmt.Print(interp.FormatError(err, opts.redact))
+	return nil
+}
+
+func soleExpr(f *syntax.File) syntax.Expr {
+	if len(f.Stmts) == 1 {
+		if stmt, ok := f.Stmts[0].(*syntax.ExprStmt); ok {
+			return stmt.X
+		}
+	}
+	return nil
+}
+
+// pretty formats lists and dicts, such as the results of file.list, as indented JSON. Everything
+// else is printed as starlark would
+func pretty(v starlark.Value) string {
+	switch v.(type) {
+	case *starlark.List, *starlark.Dict, starlark.Tuple:
+		gv, err := modules.ToGolangValue(v)
+		if err != nil {
+			break
+		}
+		buf, err := json.MarshalIndent(gv, "", "  ")
+		if err != nil {
+			break
+		}
+		return string(buf)
+	}
+	return v.String()
+}
+
+// completer completes global names and the attributes of modules
+type completer struct {
+	globals starlark.StringDict
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Do implements readline.AutoCompleter. It returns the suffixes of the matching names
+func (c *completer) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && (isIdent(line[start-1]) || line[start-1] == '.') {
+		start--
+	}
+	word := string(line[start:pos])
+
+	var names []string
+	prefix := word
+	if dot := strings.LastIndex(word, "."); dot >= 0 {
+		prefix = word[dot+1:]
+		if v := c.lookup(word[:dot]); v != nil {
+			if attrs, ok := v.(starlark.HasAttrs); ok {
+				names = attrs.AttrNames()
+			}
+		}
+	} else {
+		for k := range c.globals {
+			names = append(names, k)
+		}
+		for k := range starlark.Universe {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	res := make([][]rune, 0, len(names))
+	for _, n := range names {
+		if strings.HasPrefix(n, prefix) {
+			res = append(res, []rune(n[len(prefix):]))
+		}
+	}
+	return res, len([]rune(prefix))
+}
+
+// lookup resolves a dotted name such as "sys" without calling anything
+func (c *completer) lookup(name string) starlark.Value {
+	parts := strings.Split(name, ".")
+	v, ok := c.globals[parts[0]]
+	if !ok {
+		v, ok = starlark.Universe[parts[0]]
+	}
+	if !ok {
+		return nil
+	}
+	for _, p := range parts[1:] {
+		attrs, ok := v.(starlark.HasAttrs)
+		if !ok {
+			return nil
+		}
+		v, _ = attrs.Attr(p)
+		if v == nil {
+			return nil
+		}
+	}
+	return v
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nullmonk/gnome"
+)
+
+// testMain runs the test scripts found in the paths given on the command line
+func testMain(args []string) {
+	var opts options
+	flags := flag.NewFlagSet("gnome test", flag.ExitOnError)
+	opts.register(flags)
+	asJson := flags.Bool("json", false, "print the results of the tests as JSON")
+	verbose := flags.Bool("v", false, "print every test and its output, not only failures")
+	run := flags.String("run", "", "only run the tests matching the `regexp`")
+	timeout := flags.Duration("timeout", 0, "maximum `duration` of each test")
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	var match func(string) bool
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Printf("[!] %s\n", err)
+			os.Exit(1)
+		}
+		match = re.MatchString
+	}
+
+	interp := opts.interpreter()
+	interp.Options.ScriptTimeout = *timeout
+	results, err := interp.Test(context.Background(), paths, match)
+	if err != nil {
+		fmt.Printf("[!] %s\n", err)
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if !r.Passed() {
+			failed++
+		}
+	}
+	if *asJson {
+		for k, r := range results {
+			if r.Err != nil && opts.redact {
+				results[k].Err, results[k].Backtrace = redactError(interp, r.Err)
+			}
+		}
+		buf, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(buf))
+	} else {
+		for _, r := range results {
+			printTest(interp, &opts, r, *verbose)
+		}
+		if failed > 0 {
+			fmt.Printf("FAIL: %d of %d tests failed\n", failed, len(results))
+		} else {
+			fmt.Printf("ok: %d tests passed\n", len(results))
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func printTest(interp *gnome.Interpreter, opts *options, r gnome.TestResult, verbose bool) {
+	if r.Passed() && !verbose {
+		return
+	}
+	status := "PASS"
+	if !r.Passed() {
+		status = "FAIL"
+	}
+	name := r.File
+	if r.Name != "" {
+		name += " " + r.Name
+	}
+	fmt.Printf("--- %s: %s (%s)\n", status, name, r.Duration.Round(time.Microsecond))
+	if r.Err != nil {
+		fmt.Print(indent(opts.errorReport(interp, r.Err)))
+	}
+	if r.Output != "" {
+		fmt.Print(indent(r.Output))
+	}
+}
+
+func indent(s string) string {
+	s = strings.TrimRight(s, "\n")
+	return "    " + strings.ReplaceAll(s, "\n", "\n    ") + "\n"
+}
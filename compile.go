@@ -0,0 +1,151 @@
+package gnome
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+)
+
+// CompiledExt is the extension of scripts compiled to starlark bytecode
+const CompiledExt = ".eldrc"
+
+// compiledMagic starts every compiled script. It is followed by a line with the JSON
+// compiledHeader, then the bytecode
+const compiledMagic = "gnome-compiled 1\n"
+
+// compiledHeader describes the source a script was compiled from
+type compiledHeader struct {
+	// Source is the hex sha256 of the source, so a changed source runs instead of the bytecode
+	Source string `json:"source"`
+	// Pragma is the leading comment block of the source, so the pragmas apply without the source
+	Pragma string `json:"pragma,omitempty"`
+	// Globals are the globals the script defines, so scripts after it can be checked without the
+	// source
+	Globals []string `json:"globals,omitempty"`
+}
+
+func sourceHash(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
+
+// decodeCompiled splits a compiled script into its header and bytecode
+func decodeCompiled(compiled []byte) (compiledHeader, []byte, error) {
+	var h compiledHeader
+	rest, ok := bytes.CutPrefix(compiled, []byte(compiledMagic))
+	if !ok {
+		return h, nil, fmt.Errorf("not a gnome compiled script")
+	}
+	line, bytecode, ok := bytes.Cut(rest, []byte("\n"))
+	if !ok {
+		return h, nil, fmt.Errorf("truncated header")
+	}
+	if err := json.Unmarshal(line, &h); err != nil {
+		return h, nil, fmt.Errorf("invalid header: %v", err)
+	}
+	return h, bytecode, nil
+}
+
+// Compile compiles a script to starlark bytecode. The modules of the interpreter, the shared global
+// and the names in globals are predeclared. The globals defined by the script are returned, so the
+// scripts that run after it can be compiled against them. The pragmas of the script and a hash of
+// its source, and the globals it defines, are kept with the bytecode
+func (i *Interpreter) Compile(name string, src []byte, globals map[string]bool) ([]byte, []string, error) {
+	if _, err := parsePragma(src); err != nil {
+		return nil, nil, fmt.Errorf("invalid pragma: %v", err)
+	}
+	isPredeclared := func(n string) bool {
+		return i.modules.Has(n) || n == sharedName || globals[n]
+	}
+	f, prog, err := starlark.SourceProgramOptions(fileOptions, name, src, isPredeclared)
+	if err != nil {
+		return nil, nil, err
+	}
+	defined := make([]string, 0)
+	if m, ok := f.Module.(*resolve.Module); ok {
+		for _, b := range m.Globals {
+			if !strings.HasPrefix(b.First.Name, "_") {
+				defined = append(defined, b.First.Name)
+			}
+		}
+	}
+
+	header, err := json.Marshal(compiledHeader{
+		Source:  sourceHash(src),
+		Pragma:  string(pragmaBlock(src)),
+		Globals: defined,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(compiledMagic)
+	buf.Write(header)
+	buf.WriteByte('\n')
+	if err := prog.Write(&buf); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), defined, nil
+}
+
+// CompiledName returns the name of the compiled form of a script, e.g. script.eldrc for both
+// script.eldr and script.eldritch. Modules with other extensions get a "c" appended, so they are
+// not mistaken for scripts
+func CompiledName(source string) string {
+	for _, ext := range []string{".eldr", ".eldritch"} {
+		if strings.HasSuffix(source, ext) {
+			return strings.TrimSuffix(source, ext) + CompiledExt
+		}
+	}
+	return source + "c"
+}
+
+// sourceNames returns the names the source of a compiled script may have, in order of preference
+func sourceNames(compiled string) []string {
+	base := strings.TrimSuffix(compiled, CompiledExt)
+	return []string{base + ".eldr", base + ".eldritch"}
+}
+
+// readSource reads the source of a compiled script, returning nil if there is none
+func readSource(readFile func(string) ([]byte, error), compiled string) []byte {
+	for _, name := range sourceNames(compiled) {
+		if buf, err := readFile(name); err == nil {
+			return buf
+		}
+	}
+	return nil
+}
+
+// execProgram runs a script, preferring the compiled form when there is one. If the compiled form
+// cannot be used, e.g. it was written by another version of starlark or the source has changed
+// since, the source runs instead and a warning is returned. A nil src is read from the file name,
+// unless the script is compiled
+func execProgram(thread *starlark.Thread, name string, src interface{}, compiled []byte, predeclared starlark.StringDict) (starlark.StringDict, string, error) {
+	warning := ""
+	if compiled != nil {
+		var prog *starlark.Program
+		h, bytecode, err := decodeCompiled(compiled)
+		if buf, ok := src.([]byte); ok && err == nil && h.Source != sourceHash(buf) {
+			err = fmt.Errorf("the source has changed since it was compiled")
+		}
+		if err == nil {
+			prog, err = starlark.CompiledProgram(bytes.NewReader(bytecode))
+		}
+		if err == nil {
+			globals, err := prog.Init(thread, predeclared)
+			return globals, "", err
+		}
+		if src == nil {
+			return nil, "", fmt.Errorf("cannot run compiled script %s: %v", name, err)
+		}
+		warning = fmt.Sprintf("compiled script is unusable, running the source instead: %v", err)
+	}
+	globals, err := starlark.ExecFileOptions(fileOptions, thread, name, src, predeclared)
+	return globals, warning, err
+}
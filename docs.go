@@ -0,0 +1,98 @@
+package gnome
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/nullmonk/gnome/modules"
+)
+
+// eldritchDocs is the upstream reference that the modules implement
+const eldritchDocs = "https://docs.realm.pub/user-guide/eldritch"
+
+// WriteDocs writes a markdown reference of the modules and builtins available to scripts and tests,
+// starting with a matrix of which functions are implemented and how they differ from Eldritch
+func (i *Interpreter) WriteDocs(w io.Writer) error {
+	testOnly := testModules()
+	libs := i.testPredeclared()
+	names := make([]string, 0, len(libs))
+	mods := make(map[string]modules.Module)
+	builtins := make([]*modules.Builtin, 0)
+	for name, v := range libs {
+		switch v := v.(type) {
+		case modules.Module:
+			mods[name] = v
+			names = append(names, name)
+		case *modules.Module:
+			mods[name] = *v
+			names = append(names, name)
+		case *modules.Builtin:
+			builtins = append(builtins, v)
+		}
+	}
+	sort.Strings(names)
+	sort.Slice(builtins, func(a, b int) bool {
+		return builtins[a].Name() < builtins[b].Name()
+	})
+
+	var b strings.Builder
+	b.WriteString("# Eldritch API Reference\n\n")
+	b.WriteString("Generated by `gnome docs`. See [language.md](language.md) for the differences in how scripts run.\n\n")
+
+	b.WriteString("## Compatibility\n\n")
+	fmt.Fprintf(&b, "Functions are compared to [Eldritch](%s).\n\n", eldritchDocs)
+	b.WriteString("| Function | Implemented | Differences from Eldritch |\n")
+	b.WriteString("| --- | --- | --- |\n")
+	for _, name := range names {
+		for _, f := range mods[name].Funcs() {
+			writeCompat(&b, f)
+		}
+	}
+	for _, f := range builtins {
+		writeCompat(&b, f)
+	}
+
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n## %s\n", name)
+		if testOnly.Has(name) {
+			b.WriteString("\nOnly available to tests run by `gnome test`.\n")
+		}
+		for _, f := range mods[name].Funcs() {
+			writeFunc(&b, name+".", f)
+		}
+	}
+	if len(builtins) > 0 {
+		b.WriteString("\n## Builtins\n")
+		for _, f := range builtins {
+			writeFunc(&b, "", f)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeCompat(b *strings.Builder, f *modules.Builtin) {
+	implemented := "yes"
+	if !f.Implemented() {
+		implemented = "no"
+	}
+	fmt.Fprintf(b, "| `%s` | %s | %s |\n", f.Name(), implemented, f.Differs)
+}
+
+func writeFunc(b *strings.Builder, prefix string, f *modules.Builtin) {
+	fmt.Fprintf(b, "\n### `%s%s`\n", prefix, f.Signature())
+	if !f.Implemented() {
+		b.WriteString("\n**Not implemented**, calls fail with an error.\n")
+	} else if f.Doc != "" {
+		fmt.Fprintf(b, "\n%s.\n", f.Doc)
+	}
+	if f.Effect != nil {
+		b.WriteString("\nChanges the system, dry runs only report what it would do.\n")
+	}
+	if f.Differs != "" {
+		fmt.Fprintf(b, "\n**Note:** %s.\n", f.Differs)
+	}
+}
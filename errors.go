@@ -0,0 +1,90 @@
+package gnome
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/nullmonk/gnome/modules"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// FormatError describes an error returned by a script for people: the message, the module
+// function that failed and its arguments, and the backtrace with the source line of each frame.
+// Redacting hides the values of the arguments, which may be secrets, in the call and the message,
+// and leaves out the source lines, which may spell them out
+func (i *Interpreter) FormatError(err error, redact bool) string {
+	var b strings.Builder
+	var evalErr *starlark.EvalError
+	var callErr *modules.CallError
+	isEval := errors.As(err, &evalErr)
+	switch {
+	case errors.As(err, &callErr):
+		msg := callErr.Err.Error()
+		if redact {
+			msg = modules.RedactMessage(msg, callErr.Args, callErr.Kwargs)
+		}
+		b.WriteString(msg + "\n")
+		fmt.Fprintf(&b, "in %s\n", modules.FormatCall(callErr.Function, callErr.Args, callErr.Kwargs, redact))
+	case isEval:
+		b.WriteString(evalErr.Msg + "\n")
+	default:
+		b.WriteString(err.Error() + "\n")
+	}
+	if !isEval {
+		return b.String()
+	}
+
+	b.WriteString("Traceback (most recent call last):\n")
+	sources := make(map[string][]string)
+	for _, fr := range evalErr.CallStack {
+		if !fr.Pos.IsValid() || fr.Pos.Filename() == "<builtin>" {
+			// Builtins have no source
+			fmt.Fprintf(&b, "  in %s\n", fr.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s: in %s\n", fr.Pos, fr.Name)
+		if redact {
+			continue
+		}
+		name := fr.Pos.Filename()
+		if _, ok := sources[name]; !ok {
+			sources[name] = i.sourceLines(name)
+		}
+		if line := int(fr.Pos.Line); line > 0 && line <= len(sources[name]) {
+			b.WriteString(sourceContext(sources[name][line-1], fr.Pos))
+		}
+	}
+	return b.String()
+}
+
+// sourceLines returns the lines of a script, read from the asset locker or from disk as the
+// loader would. Returns nil if the source is not available
+func (i *Interpreter) sourceLines(name string) []string {
+	var src []byte
+	var err error
+	if i.assets != nil {
+		src, err = fs.ReadFile(i.assets, name)
+	}
+	if src == nil || err != nil {
+		if src, err = os.ReadFile(name); err != nil {
+			return nil
+		}
+	}
+	return strings.Split(string(src), "\n")
+}
+
+// sourceContext shows a line of source with a caret under the column of the position
+func sourceContext(line string, pos syntax.Position) string {
+	line = strings.TrimRight(line, "\r")
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := len([]rune(line)) - len([]rune(trimmed))
+	col := int(pos.Col) - 1 - indent
+	if col < 0 {
+		col = 0
+	}
+	return fmt.Sprintf("    %s\n    %s^\n", trimmed, strings.Repeat(" ", col))
+}
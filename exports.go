@@ -0,0 +1,99 @@
+package gnome
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.starlark.net/starlark"
+)
+
+// sharedName is the global that exposes the values passed to export() by earlier scripts
+const sharedName = "shared"
+
+// exportsKey holds the values the running script has passed to export()
+const exportsKey = "gnome.exports"
+
+// exports are the values a script passes on to the scripts after it
+type exports struct {
+	// globals are the top level globals of the script, unless exports are explicit
+	globals starlark.StringDict
+	// shared are the values passed to export()
+	shared starlark.StringDict
+}
+
+/* Export values to the scripts that run after this one, as attributes of the shared global */
+func export(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) > 0 {
+		return nil, fmt.Errorf("%s: values must be passed by name, e.g. export(name=value)", b.Name())
+	}
+	pending, ok := thread.Local(exportsKey).(starlark.StringDict)
+	if !ok {
+		return nil, fmt.Errorf("%s: only available to scripts run by the interpreter", b.Name())
+	}
+	for _, kv := range kwargs {
+		// Exported values may be read by scripts running in parallel
+		kv[1].Freeze()
+		pending[string(kv[0].(starlark.String))] = kv[1]
+	}
+	return starlark.None, nil
+}
+
+// namespace is the shared global. Values are added once the script exporting them has finished,
+// so a script never sees the exports of scripts running at the same time
+type namespace struct {
+	mu     sync.RWMutex
+	values starlark.StringDict
+}
+
+func newNamespace() *namespace {
+	return &namespace{values: starlark.StringDict{}}
+}
+
+// commit makes the exported values visible to scripts that start afterwards
+func (n *namespace) commit(values starlark.StringDict) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, v := range values {
+		n.values[k] = v
+	}
+}
+
+func (n *namespace) String() string {
+	return "<shared>"
+}
+
+func (n *namespace) Type() string {
+	return "shared"
+}
+
+func (n *namespace) Freeze() {}
+
+func (n *namespace) Truth() starlark.Bool {
+	return starlark.True
+}
+
+func (n *namespace) Hash() (uint32, error) {
+	return 0, fmt.Errorf("shared is unhashable")
+}
+
+func (n *namespace) Attr(name string) (starlark.Value, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if v, ok := n.values[name]; ok {
+		return v, nil
+	}
+	return nil, nil
+}
+
+// AttrNames returns the names of all the exported values
+func (n *namespace) AttrNames() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	names := make([]string, 0, len(n.values))
+	for k := range n.values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,6 +1,8 @@
 package gnome
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"syscall"
@@ -13,7 +15,34 @@ import (
 
 // Custom functions only implemented by gnome (mostly as globals)
 
-var exitCode int64
+// ExitError is returned by Run when a script calls exit(). The embedder decides what to do with
+// the code, the library never exits the process itself
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ErrQuit marks a thread stopped by quit()
+var ErrQuit = errors.New("user quit")
+
+// haltKey holds the reason a thread was stopped by exit() or quit()
+const haltKey = "gnome.halt"
+
+// halt cancels the thread, recording why so the interpreter can tell it apart from a failure
+func halt(thread *starlark.Thread, reason error) {
+	thread.SetLocal(haltKey, reason)
+	thread.Cancel(reason.Error())
+}
+
+// Halted returns the reason the thread was stopped, an *ExitError for exit() or ErrQuit for quit().
+// Returns nil if the thread was not stopped by either
+func Halted(thread *starlark.Thread) error {
+	err, _ := thread.Local(haltKey).(error)
+	return err
+}
 
 /* Exit the interpreter preventing execution of other scripts and exiting with the given status code */
 func exit(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
@@ -21,8 +50,8 @@ func exit(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwa
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 0, &code); err != nil {
 		return nil, err
 	}
-	exitCode, _ = code.Int64()
-	thread.Cancel("user exit")
+	exitCode, _ := code.Int64()
+	halt(thread, &ExitError{Code: int(exitCode)})
 	return starlark.None, nil
 }
 
@@ -31,7 +60,7 @@ func quit(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwa
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 0); err != nil {
 		return nil, err
 	}
-	thread.Cancel("user quit")
+	halt(thread, ErrQuit)
 	return starlark.None, nil
 }
 
@@ -66,6 +95,11 @@ func fexecveat(fd uintptr, pathname string, argv []string, envv []string, flags
 	return errno
 }
 
+func fallbackEffect(args starlark.Tuple) (starlark.Value, string) {
+	path, _ := starlark.AsString(args[0])
+	return starlark.None, fmt.Sprintf("replace the process with %s and arguments %s", path, args[1])
+}
+
 // Stop execution of all the threads, then fexec a binary from either the system or the asset locker
 func fallback(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var code starlark.String
@@ -85,7 +119,7 @@ func fallback(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		argsActual = append(argsActual, i)
 	}
 
-	assets := modules.GetAssetLocker()
+	assets := modules.GetAssetLocker(thread)
 	// First, if this matches an asset, call the asset
 	if assets != nil {
 		if f, err := assets.Open(code.GoString()); err == nil {
@@ -3,9 +3,10 @@ module github.com/nullmonk/gnome
 go 1.22.0
 
 require (
+	github.com/chzyer/readline v1.5.1
 	github.com/itchyny/timefmt-go v0.1.5
 	go.starlark.net v0.0.0-20240123142251-f86470692795
 	golang.org/x/crypto v0.4.0
 )
 
-require golang.org/x/sys v0.3.0 // indirect
+require golang.org/x/sys v0.3.0
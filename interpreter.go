@@ -0,0 +1,129 @@
+package gnome
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/nullmonk/gnome/modules"
+	"go.starlark.net/starlark"
+)
+
+// Interpreter executes scripts with its own asset locker, modules, options and output. Nothing is
+// shared between interpreters, so separate interpreters may run scripts concurrently
+type Interpreter struct {
+	// Options applied by Run
+	Options RunOptions
+	// Output receives everything the scripts print. Defaults to os.Stderr. Writes are serialized,
+	// so it need not be safe for concurrent use
+	Output io.Writer
+	// Print receives everything the scripts print along with the name of the script, in place of
+	// Output. In parallel mode it is called from several goroutines, though never at the same time
+	Print func(script, msg string)
+	// Policy restricts the functions scripts may call. Nil allows everything
+	Policy *modules.Policy
+	// Interceptors wrap every call to a module function or builtin, in order. They are checked
+	// after the policy
+	Interceptors []modules.Interceptor
+	// FS is the filesystem the file module operates on. Nil uses the host filesystem
+	FS modules.FS
+
+	assets  fs.FS
+	modules starlark.StringDict
+
+	mu      sync.Mutex
+	outputs map[string]*bytes.Buffer
+	// printMu serializes the calls to Print and the writes to Output
+	printMu sync.Mutex
+}
+
+// NewInterpreter returns an interpreter with all the gnome modules, any globally registered
+// modules and no asset locker
+func NewInterpreter() *Interpreter {
+	m := builtinModules()
+	registered.Lock()
+	for k, v := range registered.values {
+		m[k] = v
+	}
+	registered.Unlock()
+	return &Interpreter{
+		Output:  os.Stderr,
+		modules: m,
+		outputs: make(map[string]*bytes.Buffer),
+	}
+}
+
+// SetAssetLocker sets the filesystem that scripts are loaded from and that the assets module reads
+func (i *Interpreter) SetAssetLocker(f fs.FS) {
+	i.assets = f
+}
+
+// AssetLocker returns the asset locker of the interpreter
+func (i *Interpreter) AssetLocker() fs.FS {
+	return i.assets
+}
+
+// Run the scripts in the asset locker followed by the given scripts
+func (i *Interpreter) Run(ctx context.Context, scripts []string, errorHandler func(script string, err error) error) ([]ScriptResult, error) {
+	return i.run(ctx, scripts, errorHandler, &i.Options)
+}
+
+// NewThread returns a thread for running code outside of Run, such as in a REPL. The thread has the
+// assets, policy and output of the interpreter, and load() works as it does in Run
+func (i *Interpreter) NewThread(ctx context.Context, name string) *starlark.Thread {
+	return i.newThread(ctx, name, newLoader(i, &i.Options))
+}
+
+// Predeclared returns a copy of the modules and builtins available to scripts
+func (i *Interpreter) Predeclared() starlark.StringDict {
+	res := make(starlark.StringDict, len(i.modules))
+	for k, v := range i.modules {
+		res[k] = v
+	}
+	return res
+}
+
+// Outputs returns everything printed during the last Run, keyed by script name
+func (i *Interpreter) Outputs() map[string]string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	res := make(map[string]string, len(i.outputs))
+	for k, v := range i.outputs {
+		res[k] = v.String()
+	}
+	return res
+}
+
+// outputKey holds the buffer capturing the output of the script the thread runs for. Threads
+// without one, such as in a REPL, are not captured
+const outputKey = "gnome.output"
+
+// print captures the output of the script before passing it on to Print or Output. Output from
+// loaded modules belongs to the script loading them
+func (i *Interpreter) print(thread *starlark.Thread, msg string) {
+	if buf, ok := thread.Local(outputKey).(*bytes.Buffer); ok {
+		i.mu.Lock()
+		buf.WriteString(msg + "\n")
+		i.mu.Unlock()
+	}
+
+	i.printMu.Lock()
+	defer i.printMu.Unlock()
+	if i.Print != nil {
+		i.Print(modules.Script(thread), msg)
+	} else if i.Output != nil {
+		fmt.Fprintln(i.Output, msg)
+	}
+}
+
+// defaultInterpreter backs the package level functions
+var defaultInterpreter = NewInterpreter()
+
+// SetAssetLocker sets the asset locker of the default interpreter
+func SetAssetLocker(f fs.FS) {
+	defaultInterpreter.SetAssetLocker(f)
+}
@@ -0,0 +1,191 @@
+package gnome
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nullmonk/gnome/modules"
+	"go.starlark.net/starlark"
+)
+
+// loadEntry is a module loaded by a script. ready is closed once the module has loaded
+type loadEntry struct {
+	globals starlark.StringDict
+	err     error
+	ready   chan struct{}
+}
+
+// loadStackKey holds the modules being loaded by the thread and its parents, to detect cycles
+const loadStackKey = "gnome.loading"
+
+// loader resolves load() statements for a single run, caching every module it loads. It is safe
+// for use by concurrent scripts
+type loader struct {
+	i     *Interpreter
+	opts  *RunOptions
+	mu    sync.Mutex
+	cache map[string]*loadEntry
+	// waiting maps a module being loaded to the module it waits on another script to load
+	waiting map[string]string
+}
+
+func newLoader(i *Interpreter, opts *RunOptions) *loader {
+	return &loader{
+		i:       i,
+		opts:    opts,
+		cache:   make(map[string]*loadEntry),
+		waiting: make(map[string]string),
+	}
+}
+
+// waitsOn reports if the module key waits, directly or through other modules, on the module to
+func (l *loader) waitsOn(key, to string) bool {
+	for {
+		if key == to {
+			return true
+		}
+		next, ok := l.waiting[key]
+		if !ok {
+			return false
+		}
+		key = next
+	}
+}
+
+// resolve finds the module in the asset locker, falling back to the filesystem. Paths on disk
+// are relative to the directory of the script loading them, then to the working directory. The
+// compiled form of the module is returned with the source when there is one
+func (l *loader) resolve(from, module string) (key, name string, src interface{}, compiled []byte, err error) {
+	if l.i.assets != nil {
+		name := path.Clean(module)
+		readAsset := func(name string) ([]byte, error) {
+			return fs.ReadFile(l.i.assets, name)
+		}
+		if s, ok := readModule(readAsset, name); ok {
+			return "asset:" + name, name, s.src, s.compiled, nil
+		}
+	}
+
+	candidates := []string{module}
+	if !filepath.IsAbs(module) {
+		candidates = []string{filepath.Join(filepath.Dir(from), module), module}
+	}
+	for _, c := range candidates {
+		if s, ok := readModule(os.ReadFile, c); ok {
+			key := c
+			if abs, err := filepath.Abs(c); err == nil {
+				key = abs
+			}
+			return key, c, s.src, s.compiled, nil
+		}
+	}
+	return "", "", nil, nil, fmt.Errorf("module not found in assets or on disk")
+}
+
+// readModule reads a module and its compiled form. If name is the compiled module, the source is
+// read from next to it
+func readModule(readFile func(string) ([]byte, error), name string) (script, bool) {
+	var s script
+	if strings.HasSuffix(name, CompiledExt) {
+		buf, err := readFile(name)
+		if err != nil {
+			return s, false
+		}
+		s.compiled = buf
+		if buf := readSource(readFile, name); buf != nil {
+			s.src = buf
+		}
+		return s, true
+	}
+
+	buf, err := readFile(name)
+	if err != nil {
+		return s, false
+	}
+	s.src = buf
+	if buf, err := readFile(CompiledName(name)); err == nil {
+		s.compiled = buf
+	}
+	return s, true
+}
+
+// Load implements starlark.Thread.Load. Loaded modules only see the gnome modules, not the globals
+// of other scripts, and their globals are frozen once loaded. They run under the context of the
+// thread loading them, so its timeout applies to them too
+func (l *loader) Load(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+	key, name, src, compiled, err := l.resolve(thread.Name, module)
+	if err != nil {
+		return nil, err
+	}
+
+	stack, _ := thread.Local(loadStackKey).([]string)
+	for _, k := range stack {
+		if k == key {
+			return nil, fmt.Errorf("cycle in load graph")
+		}
+	}
+
+	ctx := modules.Context(thread)
+	l.mu.Lock()
+	e, ok := l.cache[key]
+	if ok {
+		// Loaded or being loaded by another script. If that script waits on the module this thread
+		// is loading, neither would finish
+		if len(stack) == 0 {
+			l.mu.Unlock()
+			return l.wait(ctx, e)
+		}
+		loading := stack[len(stack)-1]
+		if l.waitsOn(key, loading) {
+			l.mu.Unlock()
+			return nil, fmt.Errorf("cycle in load graph")
+		}
+		l.waiting[loading] = key
+		l.mu.Unlock()
+		defer func() {
+			l.mu.Lock()
+			delete(l.waiting, loading)
+			l.mu.Unlock()
+		}()
+		return l.wait(ctx, e)
+	}
+	e = &loadEntry{ready: make(chan struct{})}
+	l.cache[key] = e
+	l.mu.Unlock()
+	defer close(e.ready)
+
+	child := l.i.newThread(ctx, name, l)
+	// The module runs for the script loading it, so the policy and output of that script apply
+	modules.SetScript(child, modules.Script(thread))
+	child.SetLocal(outputKey, thread.Local(outputKey))
+	child.SetLocal(loadStackKey, append(stack[:len(stack):len(stack)], key))
+	stop := context.AfterFunc(ctx, func() {
+		child.Cancel(context.Cause(ctx).Error())
+	})
+	e.globals, _, e.err = execProgram(child, name, src, compiled, l.i.modules)
+	stop()
+	if h := Halted(child); h != nil {
+		// exit() and quit() in a loaded module stop the script that loaded it
+		halt(thread, h)
+	}
+	if e.err == nil {
+		e.globals.Freeze()
+	}
+	return e.globals, e.err
+}
+
+// wait returns the module once it has loaded
+func (l *loader) wait(ctx context.Context, e *loadEntry) (starlark.StringDict, error) {
+	select {
+	case <-e.ready:
+		return e.globals, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
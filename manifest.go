@@ -0,0 +1,143 @@
+package gnome
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/nullmonk/gnome/modules"
+)
+
+// ManifestName is the file in the asset locker that controls how the asset scripts run
+const ManifestName = "gnome.json"
+
+// Manifest lists the asset scripts to run. Scripts in the asset locker that are not listed run
+// after the listed ones, in lexical order
+type Manifest struct {
+	Scripts []ManifestScript `json:"scripts"`
+}
+
+// ManifestScript controls when and how an asset script runs
+type ManifestScript struct {
+	// Name is the path of the script in the asset locker
+	Name string `json:"name"`
+	// Order sorts the scripts, lowest first. Scripts with the same order keep the manifest order
+	Order int `json:"order,omitempty"`
+	// DependsOn lists scripts that must complete without error before this one runs
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Platforms limits the script to the platforms returned by sys.get_os(), e.g. PLATFORM_LINUX
+	Platforms []string `json:"platforms,omitempty"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+	// Timeout limits the execution time of the script, e.g. "30s"
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// loadManifest reads the manifest from the asset locker. A missing manifest is not an error
+func loadManifest(assets fs.FS) (*Manifest, error) {
+	buf, err := fs.ReadFile(assets, ManifestName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(buf, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// apply orders the asset scripts according to the manifest and marks the ones that must not run
+func (m *Manifest) apply(assets []script) ([]script, error) {
+	byName := make(map[string]*script, len(assets))
+	for i := range assets {
+		byName[assets[i].name] = &assets[i]
+	}
+
+	entries := make(map[string]*ManifestScript, len(m.Scripts))
+	for i := range m.Scripts {
+		e := &m.Scripts[i]
+		if _, ok := byName[e.Name]; !ok {
+			return nil, fmt.Errorf("script '%s' not found in assets", e.Name)
+		}
+		if _, ok := entries[e.Name]; ok {
+			return nil, fmt.Errorf("script '%s' listed twice", e.Name)
+		}
+		entries[e.Name] = e
+	}
+
+	sorted := make([]*ManifestScript, 0, len(m.Scripts))
+	for i := range m.Scripts {
+		sorted = append(sorted, &m.Scripts[i])
+	}
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].Order < sorted[b].Order
+	})
+
+	platform, _ := modules.GetOS()
+	res := make([]script, 0, len(assets))
+	state := make(map[string]int) // 1 visiting, 2 done
+	var visit func(e *ManifestScript) error
+	visit = func(e *ManifestScript) error {
+		switch state[e.Name] {
+		case 1:
+			return fmt.Errorf("dependency cycle at script '%s'", e.Name)
+		case 2:
+			return nil
+		}
+		state[e.Name] = 1
+		for _, dep := range e.DependsOn {
+			d, ok := entries[dep]
+			if !ok {
+				return fmt.Errorf("script '%s' depends on unlisted script '%s'", e.Name, dep)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[e.Name] = 2
+
+		s := *byName[e.Name]
+		s.dependsOn = e.DependsOn
+		if e.Timeout != "" {
+			t, err := time.ParseDuration(e.Timeout)
+			if err != nil {
+				return fmt.Errorf("script '%s' has invalid timeout: %v", e.Name, err)
+			}
+			s.timeout = t
+		}
+		if e.Enabled != nil && !*e.Enabled {
+			s.skip = "disabled in " + ManifestName
+		} else if len(e.Platforms) > 0 && !contains(e.Platforms, platform) {
+			s.skip = "platform " + platform + " not in " + ManifestName
+		}
+		res = append(res, s)
+		return nil
+	}
+	for _, e := range sorted {
+		if err := visit(e); err != nil {
+			return nil, err
+		}
+	}
+
+	// Anything not in the manifest runs afterwards
+	for _, s := range assets {
+		if _, ok := entries[s.name]; !ok {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
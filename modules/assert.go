@@ -0,0 +1,171 @@
+package modules
+
+import (
+	"fmt"
+	"regexp"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// AssertionError is returned by a failed assertion
+type AssertionError struct {
+	Msg string
+}
+
+func (e *AssertionError) Error() string {
+	return "assertion failed: " + e.Msg
+}
+
+// fail returns an assertion error, prefixed by the message given by the script if there is one
+func fail(msg starlark.String, format string, args ...interface{}) error {
+	s := fmt.Sprintf(format, args...)
+	if msg != "" {
+		s = msg.GoString() + ": " + s
+	}
+	return &AssertionError{Msg: s}
+}
+
+func assertEq(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var a, b starlark.Value
+	var msg starlark.String
+	if err := starlark.UnpackPositionalArgs("", args, kwargs, 2, &a, &b, &msg); err != nil {
+		return nil, err
+	}
+	eq, err := starlark.Equal(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if !eq {
+		return nil, fail(msg, "%s != %s", a, b)
+	}
+	return starlark.None, nil
+}
+
+func assertNe(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var a, b starlark.Value
+	var msg starlark.String
+	if err := starlark.UnpackPositionalArgs("", args, kwargs, 2, &a, &b, &msg); err != nil {
+		return nil, err
+	}
+	eq, err := starlark.Equal(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if eq {
+		return nil, fail(msg, "%s == %s", a, b)
+	}
+	return starlark.None, nil
+}
+
+func assertTrue(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var cond starlark.Value
+	var msg starlark.String
+	if err := starlark.UnpackPositionalArgs("", args, kwargs, 1, &cond, &msg); err != nil {
+		return nil, err
+	}
+	if !cond.Truth() {
+		return nil, fail(msg, "%s is not true", cond)
+	}
+	return starlark.None, nil
+}
+
+func assertContains(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var container, item starlark.Value
+	var msg starlark.String
+	if err := starlark.UnpackPositionalArgs("", args, kwargs, 2, &container, &item, &msg); err != nil {
+		return nil, err
+	}
+	found, err := starlark.Binary(syntax.IN, item, container)
+	if err != nil {
+		return nil, err
+	}
+	if !found.Truth() {
+		return nil, fail(msg, "%s does not contain %s", container, item)
+	}
+	return starlark.None, nil
+}
+
+// Calls fn and returns the message of the error it fails with
+func assertFails(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var fn starlark.Callable
+	var pattern, msg starlark.String
+	if err := starlark.UnpackPositionalArgs("", args, kwargs, 1, &fn, &pattern, &msg); err != nil {
+		return nil, err
+	}
+	_, err := starlark.Call(thread, fn, nil, nil)
+	if err == nil {
+		return nil, fail(msg, "%s did not fail", fn.Name())
+	}
+	text := err.Error()
+	if e, ok := err.(*starlark.EvalError); ok {
+		text = e.Msg
+	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern.GoString())
+		if err != nil {
+			return nil, err
+		}
+		if !re.MatchString(text) {
+			return nil, fail(msg, "error %q does not match %q", text, pattern)
+		}
+	}
+	return starlark.String(text), nil
+}
+
+var Assert = NewModule("assert", []Func{
+	{
+		Name: "contains",
+		Params: []Param{
+			{Name: "container"},
+			{Name: "item"},
+			{Name: "msg", Type: "string", Default: starlark.String("")},
+		},
+		Doc:     "Fails unless item is in container",
+		Differs: "Not in Eldritch",
+		Fn:      assertContains,
+	},
+	{
+		Name: "eq",
+		Params: []Param{
+			{Name: "a"},
+			{Name: "b"},
+			{Name: "msg", Type: "string", Default: starlark.String("")},
+		},
+		Doc:     "Fails unless a equals b",
+		Differs: "Not in Eldritch",
+		Fn:      assertEq,
+	},
+	{
+		Name: "fails",
+		Params: []Param{
+			{Name: "fn"},
+			{Name: "pattern", Type: "string", Default: starlark.String("")},
+			{Name: "msg", Type: "string", Default: starlark.String("")},
+		},
+		Doc:     "Calls fn, failing unless it fails with an error matching the regular expression pattern. Returns the error message",
+		Differs: "Not in Eldritch",
+		Fn:      assertFails,
+	},
+	{
+		Name: "ne",
+		Params: []Param{
+			{Name: "a"},
+			{Name: "b"},
+			{Name: "msg", Type: "string", Default: starlark.String("")},
+		},
+		Doc:     "Fails if a equals b",
+		Differs: "Not in Eldritch",
+		Fn:      assertNe,
+	},
+	{
+		Name: "true",
+		Params: []Param{
+			{Name: "cond"},
+			{Name: "msg", Type: "string", Default: starlark.String("")},
+		},
+		Doc:     "Fails unless cond is true",
+		Differs: "Not in Eldritch",
+		Fn:      assertTrue,
+	},
+})
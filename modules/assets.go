@@ -15,10 +15,11 @@ func assetsList(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 0); err != nil {
 		return nil, err
 	}
+	assetLocker := GetAssetLocker(thread)
 	if assetLocker == nil {
 		return starlark.None, fmt.Errorf("asset locker not initialized")
 	}
-	return ToStarlarkValue(GetAssets())
+	return ToStarlarkValue(GetAssets(assetLocker))
 }
 
 func assetsCopy(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
@@ -27,6 +28,7 @@ func assetsCopy(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 2, &name, &dst); err != nil {
 		return nil, err
 	}
+	assetLocker := GetAssetLocker(thread)
 	if assetLocker == nil {
 		return starlark.None, fmt.Errorf("asset locker not initialized")
 	}
@@ -36,13 +38,16 @@ func assetsCopy(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 		return starlark.None, err
 	}
 
-	d, err := os.Create(dst.GoString())
+	defer f.Close()
+	d, err := GetFS(thread).OpenFile(dst.GoString(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return starlark.None, err
 	}
-	defer f.Close()
-	_, err = io.Copy(d, f)
-	return starlark.None, err
+	if _, err = io.Copy(d, f); err != nil {
+		d.Close()
+		return starlark.None, err
+	}
+	return starlark.None, d.Close()
 }
 
 func assetsRead(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
@@ -50,6 +55,7 @@ func assetsRead(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 1, &name); err != nil {
 		return nil, err
 	}
+	assetLocker := GetAssetLocker(thread)
 	if assetLocker == nil {
 		return starlark.None, fmt.Errorf("asset locker not initialized")
 	}
@@ -70,6 +76,7 @@ func assetsReadBinary(thread *starlark.Thread, _ *starlark.Builtin, args starlar
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 1, &name); err != nil {
 		return nil, err
 	}
+	assetLocker := GetAssetLocker(thread)
 	if assetLocker == nil {
 		return starlark.None, fmt.Errorf("asset locker not initialized")
 	}
@@ -85,17 +92,21 @@ func assetsReadBinary(thread *starlark.Thread, _ *starlark.Builtin, args starlar
 	return starlark.Bytes(buf), nil
 }
 
-var assetLocker fs.FS
+const assetsKey = "gnome.assets"
 
-func SetAssetLocker(f fs.FS) {
-	assetLocker = f
+// SetAssetLocker sets the asset locker used by the scripts running on the thread
+func SetAssetLocker(thread *starlark.Thread, f fs.FS) {
+	thread.SetLocal(assetsKey, f)
 }
 
-func GetAssetLocker() fs.FS {
-	return assetLocker
+// GetAssetLocker returns the asset locker of the thread, or nil if there is none
+func GetAssetLocker(thread *starlark.Thread) fs.FS {
+	f, _ := thread.Local(assetsKey).(fs.FS)
+	return f
 }
 
-func GetAssets() []string {
+// GetAssets lists the names of all the files in the asset locker
+func GetAssets(assetLocker fs.FS) []string {
 	assets := make([]string, 0, 64)
 	fs.WalkDir(assetLocker, ".", func(path string, d fs.DirEntry, err error) error {
 		if d.IsDir() {
@@ -107,9 +118,41 @@ func GetAssets() []string {
 	return assets
 }
 
-var Assets = NewModule("assets", map[string]Function{
-	"copy":        assetsCopy,
-	"list":        assetsList,
-	"read_binary": assetsReadBinary,
-	"read":        assetsRead,
+func assetsCopyEffect(args starlark.Tuple) (starlark.Value, string) {
+	return starlark.None, fmt.Sprintf("copy the asset %s to %s", str(args[0]), str(args[1]))
+}
+
+var Assets = NewModule("assets", []Func{
+	{
+		Name: "copy",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+		},
+		Doc:     "Copies an embedded asset to dst on disk",
+		Differs: "Assets come from any fs.FS, such as an embed.FS",
+		Fn:      assetsCopy,
+		Effect:  assetsCopyEffect,
+	},
+	{
+		Name: "list",
+		Doc:  "Lists the embedded assets",
+		Fn:   assetsList,
+	},
+	{
+		Name: "read_binary",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+		},
+		Doc: "Returns the contents of an embedded asset as a list of bytes",
+		Fn:  assetsReadBinary,
+	},
+	{
+		Name: "read",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+		},
+		Doc: "Returns the contents of an embedded asset as a string",
+		Fn:  assetsRead,
+	},
 })
@@ -0,0 +1,285 @@
+package modules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// Param declares a parameter of a module function
+type Param struct {
+	Name string
+	// Type is the starlark type the argument must have, such as "string" or "list". Empty accepts
+	// any value
+	Type string
+	// Default is used when the argument is omitted. Parameters without a default are required
+	Default starlark.Value
+}
+
+// Func declares a module function. Arguments are bound to the parameters by position or by name,
+// so Fn always receives every parameter positionally and no keyword arguments
+type Func struct {
+	Name   string
+	Params []Param
+	// Variadic functions receive their arguments as they were passed, without any checks
+	Variadic bool
+	Doc      string
+	// Differs describes how the function behaves differently from Eldritch, or that Eldritch does
+	// not have it. Empty when the function matches Eldritch
+	Differs string
+	// Fn implements the function. Functions without one are not implemented and always fail
+	Fn Function
+	// Effect replaces Fn during dry runs. Set for functions that change the system
+	Effect Effect
+}
+
+// Signature formats the parameters of the function, e.g. "exec(path: string, args: list, disown: bool = False)"
+func (f Func) Signature() string {
+	if f.Variadic {
+		return f.Name + "(*args, **kwargs)"
+	}
+	params := make([]string, 0, len(f.Params))
+	for _, p := range f.Params {
+		s := p.Name
+		if p.Type != "" {
+			s += ": " + p.Type
+		}
+		if p.Default != nil {
+			s += " = " + p.Default.String()
+		}
+		params = append(params, s)
+	}
+	return f.Name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// Builtin is a module function that can be called from starlark. It enforces the policy of the
+// calling thread and binds the arguments to the declared parameters
+type Builtin struct {
+	Func
+	name    string
+	module  string
+	builtin *starlark.Builtin
+}
+
+// NewBuiltin creates a builtin from a declaration. The name is the full name of the builtin, such
+// as "file.read"
+func NewBuiltin(name string, f Func) *Builtin {
+	module := ""
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		module = name[:i]
+	}
+	return &Builtin{
+		Func:    f,
+		name:    name,
+		module:  module,
+		builtin: starlark.NewBuiltin(name, f.Fn),
+	}
+}
+
+// Name returns the full name of the builtin, such as "file.read"
+func (b *Builtin) Name() string {
+	return b.name
+}
+
+// Implemented returns false for functions that always fail because they are not implemented
+func (b *Builtin) Implemented() bool {
+	return b.Fn != nil
+}
+
+func (b *Builtin) String() string {
+	return fmt.Sprintf("<built-in function %s>", b.name)
+}
+
+func (b *Builtin) Type() string {
+	return "builtin_function_or_method"
+}
+
+func (b *Builtin) Freeze() {}
+
+func (b *Builtin) Truth() starlark.Bool {
+	return starlark.True
+}
+
+func (b *Builtin) Hash() (uint32, error) {
+	return starlark.String(b.name).Hash()
+}
+
+func (b *Builtin) CallInternal(thread *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	call := &Call{
+		Thread:   thread,
+		Module:   b.module,
+		Function: strings.TrimPrefix(b.name[len(b.module):], "."),
+		Args:     args,
+		Kwargs:   kwargs,
+		DryRun:   b.Effect != nil && DryRun(thread),
+	}
+	if err := GetPolicy(thread).Check(Script(thread), b.name); err != nil {
+		return nil, &CallError{Function: b.name, Args: args, Kwargs: kwargs, Err: err}
+	}
+	v, err := chain(GetInterceptors(thread), b.call)(call)
+	if err != nil {
+		return nil, &CallError{Function: b.name, Args: call.Args, Kwargs: call.Kwargs, Err: err}
+	}
+	return v, nil
+}
+
+// call runs the function, after the interceptors
+func (b *Builtin) call(call *Call) (starlark.Value, error) {
+	if b.Fn == nil {
+		return nil, fmt.Errorf("%s not implemented", b.name)
+	}
+	if b.Variadic {
+		return b.Fn(call.Thread, b.builtin, call.Args, call.Kwargs)
+	}
+	args, err := b.bind(call.Args, call.Kwargs)
+	if err != nil {
+		return nil, err
+	}
+	if call.DryRun {
+		return dryRun(call.Thread, b.name, b.Effect, args), nil
+	}
+	return b.Fn(call.Thread, b.builtin, args, nil)
+}
+
+// CallError is returned by a builtin that failed. It records the arguments of the call and keeps
+// the message of the underlying error
+type CallError struct {
+	Function string
+	Args     starlark.Tuple
+	Kwargs   []starlark.Tuple
+	Err      error
+}
+
+func (e *CallError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// FormatCall formats a call as a script would write it, e.g. file.read("/etc/passwd"). Redacted
+// calls keep the names of keyword arguments but hide every value
+func FormatCall(function string, args starlark.Tuple, kwargs []starlark.Tuple, redact bool) string {
+	value := func(v starlark.Value) string {
+		if redact {
+			return "<redacted>"
+		}
+		return v.String()
+	}
+	res := make([]string, 0, len(args)+len(kwargs))
+	for _, a := range args {
+		res = append(res, value(a))
+	}
+	for _, kv := range kwargs {
+		res = append(res, fmt.Sprintf("%s=%s", string(kv[0].(starlark.String)), value(kv[1])))
+	}
+	return function + "(" + strings.Join(res, ", ") + ")"
+}
+
+// minRedacted is the length of the shortest argument RedactMessage hides. Hiding shorter strings
+// would mangle the message without hiding much
+const minRedacted = 3
+
+// RedactMessage hides the string values of the arguments of a call where they appear in msg, such
+// as the path in "open /etc/shadow: permission denied"
+func RedactMessage(msg string, args starlark.Tuple, kwargs []starlark.Tuple) string {
+	values := make([]string, 0, len(args)+len(kwargs))
+	var collect func(v starlark.Value)
+	collect = func(v starlark.Value) {
+		switch v := v.(type) {
+		case starlark.String:
+			values = append(values, string(v))
+		case starlark.Bytes:
+			values = append(values, string(v))
+		case *starlark.Dict:
+			for _, item := range v.Items() {
+				collect(item[0])
+				collect(item[1])
+			}
+		case starlark.Iterable:
+			iter := v.Iterate()
+			defer iter.Done()
+			var elem starlark.Value
+			for iter.Next(&elem) {
+				collect(elem)
+			}
+		}
+	}
+	for _, a := range args {
+		collect(a)
+	}
+	for _, kv := range kwargs {
+		collect(kv[1])
+	}
+	// Longer values first, so values containing others are hidden whole
+	sort.Slice(values, func(a, b int) bool {
+		return len(values[a]) > len(values[b])
+	})
+	for _, v := range values {
+		if len(v) >= minRedacted {
+			msg = strings.ReplaceAll(msg, v, "<redacted>")
+		}
+	}
+	return msg
+}
+
+// bind matches the arguments to the parameters, filling in defaults and checking types
+func (b *Builtin) bind(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Tuple, error) {
+	if len(args) > len(b.Params) {
+		return nil, fmt.Errorf("%s: got %d arguments, want at most %d", b.name, len(args), len(b.Params))
+	}
+	res := make(starlark.Tuple, len(b.Params))
+	copy(res, args)
+
+	for _, kv := range kwargs {
+		name := string(kv[0].(starlark.String))
+		i := b.param(name)
+		if i < 0 {
+			return nil, fmt.Errorf("%s: unexpected keyword argument %s", b.name, name)
+		}
+		if res[i] != nil {
+			return nil, fmt.Errorf("%s: got multiple values for parameter %s", b.name, name)
+		}
+		res[i] = kv[1]
+	}
+
+	for i, p := range b.Params {
+		if res[i] == nil {
+			if p.Default == nil {
+				return nil, fmt.Errorf("%s: missing argument for %s", b.name, p.Name)
+			}
+			res[i] = p.Default
+		}
+		if p.Type != "" && res[i].Type() != p.Type {
+			return nil, fmt.Errorf("%s: for parameter %s: got %s, want %s", b.name, p.Name, res[i].Type(), p.Type)
+		}
+	}
+	return res, nil
+}
+
+// param returns the index of the parameter with the given name, or -1
+func (b *Builtin) param(name string) int {
+	for i, p := range b.Params {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Funcs returns the functions of the module sorted by name
+func (m Module) Funcs() []*Builtin {
+	res := make([]*Builtin, 0, len(m))
+	for _, v := range m {
+		if b, ok := v.(*Builtin); ok {
+			res = append(res, b)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name() < res[j].Name()
+	})
+	return res
+}
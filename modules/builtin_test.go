@@ -0,0 +1,83 @@
+package modules
+
+import (
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+func TestBind(t *testing.T) {
+	b := NewBuiltin("file.copy", Func{
+		Name: "copy",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+			{Name: "mode", Type: "int", Default: starlark.MakeInt(0644)},
+		},
+	})
+	kw := func(name string, v starlark.Value) starlark.Tuple {
+		return starlark.Tuple{starlark.String(name), v}
+	}
+	a, c := starlark.String("/a"), starlark.String("/c")
+	tests := []struct {
+		name   string
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		want   starlark.Tuple
+		err    string
+	}{
+		{"positional", starlark.Tuple{a, c}, nil, starlark.Tuple{a, c, starlark.MakeInt(0644)}, ""},
+		{"all positional", starlark.Tuple{a, c, starlark.MakeInt(0600)}, nil, starlark.Tuple{a, c, starlark.MakeInt(0600)}, ""},
+		{"keywords", nil, []starlark.Tuple{kw("dst", c), kw("src", a)}, starlark.Tuple{a, c, starlark.MakeInt(0644)}, ""},
+		{"mixed", starlark.Tuple{a}, []starlark.Tuple{kw("mode", starlark.MakeInt(0600)), kw("dst", c)}, starlark.Tuple{a, c, starlark.MakeInt(0600)}, ""},
+		{"too many", starlark.Tuple{a, c, starlark.MakeInt(1), starlark.MakeInt(2)}, nil, nil, "file.copy: got 4 arguments, want at most 3"},
+		{"unknown keyword", starlark.Tuple{a, c}, []starlark.Tuple{kw("force", starlark.True)}, nil, "file.copy: unexpected keyword argument force"},
+		{"duplicate", starlark.Tuple{a, c}, []starlark.Tuple{kw("src", a)}, nil, "file.copy: got multiple values for parameter src"},
+		{"duplicate keyword", starlark.Tuple{a}, []starlark.Tuple{kw("dst", c), kw("dst", c)}, nil, "file.copy: got multiple values for parameter dst"},
+		{"missing", starlark.Tuple{a}, nil, nil, "file.copy: missing argument for dst"},
+		{"wrong type", starlark.Tuple{a, starlark.MakeInt(1)}, nil, nil, "file.copy: for parameter dst: got int, want string"},
+		{"wrong keyword type", starlark.Tuple{a, c}, []starlark.Tuple{kw("mode", starlark.String("0644"))}, nil, "file.copy: for parameter mode: got string, want int"},
+	}
+	for _, tt := range tests {
+		got, err := b.bind(tt.args, tt.kwargs)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: bind() error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: bind() error = %v", tt.name, err)
+			continue
+		}
+		if eq, err := starlark.Equal(got, tt.want); err != nil || !eq {
+			t.Errorf("%s: bind() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRedactMessage(t *testing.T) {
+	kw := func(name string, v starlark.Value) []starlark.Tuple {
+		return []starlark.Tuple{{starlark.String(name), v}}
+	}
+	tests := []struct {
+		name   string
+		msg    string
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		want   string
+	}{
+		{"path", "open /etc/shadow: permission denied", starlark.Tuple{starlark.String("/etc/shadow")}, nil, "open <redacted>: permission denied"},
+		{"longest first", "rename /etc /etc/shadow: busy", starlark.Tuple{starlark.String("/etc"), starlark.String("/etc/shadow")}, nil, "rename <redacted> <redacted>: busy"},
+		{"keyword", "open /tmp/key: no such file", nil, kw("path", starlark.String("/tmp/key")), "open <redacted>: no such file"},
+		{"nested", "exec hunter2: not found", starlark.Tuple{starlark.NewList([]starlark.Value{starlark.Tuple{starlark.String("hunter2")}})}, nil, "exec <redacted>: not found"},
+		{"bytes", "bad token s3cret", starlark.Tuple{starlark.Bytes("s3cret")}, nil, "bad token <redacted>"},
+		{"too short", "open a: no such file", starlark.Tuple{starlark.String("a")}, nil, "open a: no such file"},
+		{"not strings", "kill 1234: no such process", starlark.Tuple{starlark.MakeInt(1234)}, nil, "kill 1234: no such process"},
+	}
+	for _, tt := range tests {
+		if got := RedactMessage(tt.msg, tt.args, tt.kwargs); got != tt.want {
+			t.Errorf("%s: RedactMessage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
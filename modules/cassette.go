@@ -0,0 +1,411 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go.starlark.net/starlark"
+)
+
+// CassetteEntry is a module function call recorded to a cassette. A cassette is a JSONL file with
+// one entry per line. Values JSON cannot tell apart are tagged with their type, e.g.
+// {"$tuple": [1, 2]}, {"$bytes": "<base64>"} or {"$float": "1"}, so replay restores them as they were
+type CassetteEntry struct {
+	Script   string                 `json:"script"`
+	Function string                 `json:"function"`
+	Args     []interface{}          `json:"args"`
+	Kwargs   map[string]interface{} `json:"kwargs,omitempty"`
+	Result   interface{}            `json:"result"`
+	Error    string                 `json:"error,omitempty"`
+	// Code is the errno of the error, such as ENOENT, and Op and Path are set for errors about a
+	// path, so replay rebuilds the error scripts see
+	Code string `json:"code,omitempty"`
+	Op   string `json:"op,omitempty"`
+	Path string `json:"path,omitempty"`
+	// DryRun marks calls recorded during a dry run, whose results are placeholders that replay
+	// refuses
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// setErr records an error
+func (e *CassetteEntry) setErr(err error) {
+	e.Error = err.Error()
+	e.Code = ErrorCode(err)
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	if errors.As(err, &pathErr) {
+		e.Op, e.Path = pathErr.Op, pathErr.Path
+	} else if errors.As(err, &linkErr) {
+		e.Op, e.Path = linkErr.Op, linkErr.Old
+	}
+}
+
+// err rebuilds the recorded error. Errors with an errno unwrap to it, and errors about a path are
+// an *fs.PathError
+func (e *CassetteEntry) err() error {
+	errno, ok := errnoValue(e.Code)
+	if !ok {
+		return errors.New(e.Error)
+	}
+	if e.Path != "" {
+		return &fs.PathError{Op: e.Op, Path: e.Path, Err: errno}
+	}
+	return &replayedError{msg: e.Error, errno: errno}
+}
+
+// replayedError is a recorded error with an errno but no path
+type replayedError struct {
+	msg   string
+	errno syscall.Errno
+}
+
+func (e *replayedError) Error() string {
+	return e.msg
+}
+
+func (e *replayedError) Unwrap() error {
+	return e.errno
+}
+
+// key identifies the calls that the entry answers
+func (e *CassetteEntry) key() string {
+	buf, _ := json.Marshal([]interface{}{e.Function, e.Args, e.Kwargs})
+	return string(buf)
+}
+
+// newEntry converts a call to an entry
+func newEntry(call *Call) *CassetteEntry {
+	e := &CassetteEntry{
+		Script:   call.Thread.Name,
+		Function: call.Name(),
+		Args:     make([]interface{}, 0, len(call.Args)),
+		DryRun:   call.DryRun,
+	}
+	for _, a := range call.Args {
+		e.Args = append(e.Args, encodeValue(a))
+	}
+	if len(call.Kwargs) > 0 {
+		e.Kwargs = make(map[string]interface{}, len(call.Kwargs))
+		for _, kv := range call.Kwargs {
+			e.Kwargs[string(kv[0].(starlark.String))] = encodeValue(kv[1])
+		}
+	}
+	return e
+}
+
+// encodeValue converts a value to its cassette form. Values that cannot be replayed, such as
+// functions, are recorded as their string representation
+func encodeValue(v starlark.Value) interface{} {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(v)
+	case starlark.String:
+		return string(v)
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i
+		}
+		return tagged("$int", v.String())
+	case starlark.Float:
+		return tagged("$float", strconv.FormatFloat(float64(v), 'g', -1, 64))
+	case starlark.Bytes:
+		return tagged("$bytes", base64.StdEncoding.EncodeToString([]byte(v)))
+	case starlark.Tuple:
+		return tagged("$tuple", encodeValues(v))
+	case *starlark.List:
+		return encodeValues(listValues(v))
+	case *starlark.Set:
+		return tagged("$set", encodeValues(listValues(v)))
+	case *starlark.Dict:
+		obj := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			k, ok := item[0].(starlark.String)
+			if !ok || (v.Len() == 1 && strings.HasPrefix(string(k), "$")) {
+				// Not representable as a JSON object, or mistaken for a tag
+				pairs := make([]interface{}, 0, v.Len())
+				for _, item := range v.Items() {
+					pairs = append(pairs, encodeValues(item[:]))
+				}
+				return tagged("$dict", pairs)
+			}
+			obj[string(k)] = encodeValue(item[1])
+		}
+		return obj
+	default:
+		return tagged("$repr", v.String())
+	}
+}
+
+func encodeValues(values []starlark.Value) []interface{} {
+	res := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		res = append(res, encodeValue(v))
+	}
+	return res
+}
+
+func listValues(iterable starlark.Iterable) []starlark.Value {
+	var res []starlark.Value
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var v starlark.Value
+	for iter.Next(&v) {
+		res = append(res, v)
+	}
+	return res
+}
+
+func tagged(tag string, v interface{}) map[string]interface{} {
+	return map[string]interface{}{tag: v}
+}
+
+// decodeValue converts a value in its cassette form, as decoded with json.Decoder.UseNumber, back
+// to starlark
+func decodeValue(x interface{}) (starlark.Value, error) {
+	switch x := x.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(x), nil
+	case string:
+		return starlark.String(x), nil
+	case json.Number:
+		if strings.ContainsAny(string(x), ".eE") {
+			f, err := x.Float64()
+			return starlark.Float(f), err
+		}
+		return parseInt(string(x))
+	case []interface{}:
+		values, err := decodeValues(x)
+		return starlark.NewList(values), err
+	case map[string]interface{}:
+		if len(x) == 1 {
+			for tag, v := range x {
+				if strings.HasPrefix(tag, "$") {
+					return decodeTagged(tag, v)
+				}
+			}
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := starlark.NewDict(len(x))
+		for _, k := range keys {
+			v, err := decodeValue(x[k])
+			if err != nil {
+				return nil, err
+			}
+			d.SetKey(starlark.String(k), v)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("unsupported cassette value %v", x)
+}
+
+func decodeTagged(tag string, x interface{}) (starlark.Value, error) {
+	s, _ := x.(string)
+	items, _ := x.([]interface{})
+	switch tag {
+	case "$int":
+		return parseInt(s)
+	case "$float":
+		f, err := strconv.ParseFloat(s, 64)
+		return starlark.Float(f), err
+	case "$bytes":
+		buf, err := base64.StdEncoding.DecodeString(s)
+		return starlark.Bytes(buf), err
+	case "$tuple":
+		values, err := decodeValues(items)
+		return starlark.Tuple(values), err
+	case "$set":
+		values, err := decodeValues(items)
+		if err != nil {
+			return nil, err
+		}
+		set := starlark.NewSet(len(values))
+		for _, v := range values {
+			if err := set.Insert(v); err != nil {
+				return nil, err
+			}
+		}
+		return set, nil
+	case "$dict":
+		d := starlark.NewDict(len(items))
+		for _, item := range items {
+			pair, _ := item.([]interface{})
+			if len(pair) != 2 {
+				return nil, fmt.Errorf("invalid $dict item %v", item)
+			}
+			kv, err := decodeValues(pair)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.SetKey(kv[0], kv[1]); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	case "$repr":
+		return nil, fmt.Errorf("cannot replay %s, it was recorded as its string representation", s)
+	}
+	return nil, fmt.Errorf("unknown cassette tag %s", tag)
+}
+
+func decodeValues(items []interface{}) ([]starlark.Value, error) {
+	res := make([]starlark.Value, 0, len(items))
+	for _, item := range items {
+		v, err := decodeValue(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func parseInt(s string) (starlark.Value, error) {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid int %s", s)
+	}
+	return starlark.MakeBigInt(i), nil
+}
+
+// decodeEntry reads an entry, keeping numbers as written
+func decodeEntry(buf []byte, e *CassetteEntry) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	return dec.Decode(e)
+}
+
+// Recorder is an interceptor writing every module function call and its result to a cassette.
+// Global builtins such as exit are not recorded
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRecorder returns a recorder writing the cassette to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Intercept performs the call and records it
+func (r *Recorder) Intercept(call *Call, next Handler) (starlark.Value, error) {
+	if call.Module == "" {
+		return next(call)
+	}
+	e := newEntry(call)
+	v, callErr := next(call)
+	if callErr != nil {
+		e.setErr(callErr)
+	} else if v != nil {
+		e.Result = encodeValue(v)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(e); err != nil {
+		return nil, fmt.Errorf("%s: cannot record call: %v", e.Function, err)
+	}
+	return v, callErr
+}
+
+// Replayer is an interceptor answering module function calls from a cassette without running them.
+// Calls are matched by function and arguments. Identical calls are answered in the order they were
+// recorded, and the last answer repeats once the recorded ones run out. A call missing from the
+// cassette fails
+type Replayer struct {
+	mu      sync.Mutex
+	entries map[string][]*CassetteEntry
+}
+
+// LoadCassette reads a cassette from disk for replay
+func LoadCassette(filename string) (*Replayer, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := NewReplayer(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %v", filename, err)
+	}
+	return r, nil
+}
+
+// NewReplayer reads a cassette for replay
+func NewReplayer(cassette io.Reader) (*Replayer, error) {
+	r := &Replayer{entries: make(map[string][]*CassetteEntry)}
+	scanner := bufio.NewScanner(cassette)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := &CassetteEntry{}
+		if err := decodeEntry(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		r.entries[e.key()] = append(r.entries[e.key()], e)
+	}
+	return r, scanner.Err()
+}
+
+// Intercept answers the call from the cassette
+func (r *Replayer) Intercept(call *Call, next Handler) (starlark.Value, error) {
+	if call.Module == "" {
+		return next(call)
+	}
+	// Round trip the arguments through JSON so they compare equal to the recorded ones
+	e := newEntry(call)
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	*e = CassetteEntry{}
+	if err := decodeEntry(buf, e); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	recorded := r.entries[e.key()]
+	if len(recorded) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%s: no recorded call with these arguments", e.Function)
+	}
+	answer := recorded[0]
+	if len(recorded) > 1 {
+		r.entries[e.key()] = recorded[1:]
+	}
+	r.mu.Unlock()
+
+	if answer.DryRun {
+		return nil, fmt.Errorf("%s: recorded during a dry run, the result is not real", e.Function)
+	}
+	if answer.Error != "" {
+		return nil, answer.err()
+	}
+	v, err := decodeValue(answer.Result)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", e.Function, err)
+	}
+	return v, nil
+}
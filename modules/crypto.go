@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"hash"
 	"io"
-	"os"
 
 	"go.starlark.net/starlark"
 
@@ -132,7 +131,7 @@ func cryptoHashFile(thread *starlark.Thread, _ *starlark.Builtin, args starlark.
 		return nil, fmt.Errorf("invalid algorithm selected '%s'", algo)
 	}
 
-	f, err := os.Open(file.GoString())
+	f, err := GetFS(thread).Open(file.GoString())
 	if err != nil {
 		return nil, err
 	}
@@ -152,12 +151,66 @@ func cryptoHashFile(thread *starlark.Thread, _ *starlark.Builtin, args starlark.
 	return starlark.String(res), nil
 }
 
-var Crypto = NewModule("crypto", map[string]Function{
-	"from_json":        cryptoFromJson,
-	"to_json":          cryptoToJson,
-	"aes_encrypt_file": nil,
-	"aes_decrypt_file": nil,
-	"encode_b64":       cryptoEncodeB64,
-	"decode_b64":       cryptoDecodeB64,
-	"hash_file":        cryptoHashFile,
+var Crypto = NewModule("crypto", []Func{
+	{
+		Name: "aes_decrypt_file",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+			{Name: "key", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "aes_encrypt_file",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+			{Name: "key", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "decode_b64",
+		Params: []Param{
+			{Name: "content", Type: "string"},
+			{Name: "decode_type", Type: "string", Default: starlark.String("STANDARD")},
+		},
+		Doc: "Decodes base64 content using STANDARD, STANDARD_NO_PAD, URL_SAFE or URL_SAFE_NO_PAD",
+		Fn:  cryptoDecodeB64,
+	},
+	{
+		Name: "encode_b64",
+		Params: []Param{
+			{Name: "content", Type: "string"},
+			{Name: "encode_type", Type: "string", Default: starlark.String("STANDARD")},
+		},
+		Doc: "Encodes content as base64 using STANDARD, STANDARD_NO_PAD, URL_SAFE or URL_SAFE_NO_PAD",
+		Fn:  cryptoEncodeB64,
+	},
+	{
+		Name: "from_json",
+		Params: []Param{
+			{Name: "content", Type: "string"},
+		},
+		Doc: "Parses a JSON string into a value",
+		Fn:  cryptoFromJson,
+	},
+	{
+		Name: "hash_file",
+		Params: []Param{
+			{Name: "file", Type: "string"},
+			{Name: "algo", Type: "string"},
+		},
+		Doc: "Returns the hex digest of a file using MD5, SHA1, SHA256 or SHA512",
+		Fn:  cryptoHashFile,
+	},
+	{
+		Name: "to_json",
+		Params: []Param{
+			{Name: "content"},
+		},
+		Doc: "Serializes a value as a JSON string",
+		Fn:  cryptoToJson,
+	},
 })
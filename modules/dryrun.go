@@ -0,0 +1,44 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+
+	"go.starlark.net/starlark"
+)
+
+// Effect describes what a function with side effects would do with the given arguments, and
+// returns the value to use in place of its result during a dry run
+type Effect func(args starlark.Tuple) (placeholder starlark.Value, effect string)
+
+const dryRunKey = "gnome.dryrun"
+
+// SetDryRun sets whether the functions called by thread only report their side effects instead of
+// performing them
+func SetDryRun(thread *starlark.Thread, dryRun bool) {
+	thread.SetLocal(dryRunKey, dryRun)
+}
+
+// DryRun returns true if the functions called by thread only report their side effects
+func DryRun(thread *starlark.Thread) bool {
+	d, _ := thread.Local(dryRunKey).(bool)
+	return d
+}
+
+// dryRun reports the effect of a call through the print function of the thread
+func dryRun(thread *starlark.Thread, name string, effect Effect, args starlark.Tuple) starlark.Value {
+	v, msg := effect(args)
+	msg = fmt.Sprintf("[dry-run] %s: would %s", name, msg)
+	if thread.Print != nil {
+		thread.Print(thread, msg)
+	} else {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	return v
+}
+
+// str returns the go string of a bound string argument
+func str(v starlark.Value) string {
+	s, _ := starlark.AsString(v)
+	return s
+}
@@ -0,0 +1,51 @@
+package modules
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrorCode returns the name of the errno behind an error, such as ENOENT, or an empty string if
+// there is none. Errors of the fs package and policy denials are given the matching errno
+func ErrorCode(err error) string {
+	var errno syscall.Errno
+	var policyErr *PolicyError
+	switch {
+	case errors.As(err, &errno):
+		return unix.ErrnoName(errno)
+	case errors.As(err, &policyErr):
+		return "EPERM"
+	case errors.Is(err, fs.ErrNotExist):
+		return "ENOENT"
+	case errors.Is(err, fs.ErrExist):
+		return "EEXIST"
+	case errors.Is(err, fs.ErrPermission):
+		return "EACCES"
+	}
+	return ""
+}
+
+var (
+	errnosOnce sync.Once
+	errnos     map[string]syscall.Errno
+)
+
+// errnoValue returns the errno with the given name, such as ENOENT
+func errnoValue(name string) (syscall.Errno, bool) {
+	errnosOnce.Do(func() {
+		errnos = make(map[string]syscall.Errno)
+		for e := syscall.Errno(1); e < 4096; e++ {
+			if n := unix.ErrnoName(e); n != "" {
+				if _, ok := errnos[n]; !ok {
+					errnos[n] = e
+				}
+			}
+		}
+	})
+	e, ok := errnos[name]
+	return e, ok
+}
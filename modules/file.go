@@ -2,10 +2,11 @@ package modules
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/user"
-	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 
 	"go.starlark.net/starlark"
@@ -19,12 +20,12 @@ func fileAppend(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 		return nil, err
 	}
 
-	f, err := os.OpenFile(path.GoString(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	f, err := GetFS(thread).OpenFile(path.GoString(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	if _, err = f.WriteString(content.GoString()); err != nil {
+	if _, err = io.WriteString(f, content.GoString()); err != nil {
 		return nil, err
 	}
 	return starlark.None, nil
@@ -36,7 +37,7 @@ func fileMoveTo(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 2, &src, &dst); err != nil {
 		return nil, err
 	}
-	return starlark.None, os.Rename(src.GoString(), dst.GoString())
+	return starlark.None, GetFS(thread).Rename(src.GoString(), dst.GoString())
 }
 
 func fileIsFile(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
@@ -45,7 +46,7 @@ func fileIsFile(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 		return nil, err
 	}
 
-	if st, err := os.Stat(src.GoString()); err != nil || st.IsDir() {
+	if st, err := GetFS(thread).Stat(src.GoString()); err != nil || st.IsDir() {
 		return starlark.False, nil
 	}
 	return starlark.True, nil
@@ -57,7 +58,7 @@ func fileIsDir(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 		return nil, err
 	}
 
-	if st, err := os.Stat(src.GoString()); err != nil || !st.IsDir() {
+	if st, err := GetFS(thread).Stat(src.GoString()); err != nil || !st.IsDir() {
 		return starlark.False, nil
 	}
 	return starlark.True, nil
@@ -70,9 +71,9 @@ func fileMkDir(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 		return nil, err
 	}
 	if parent {
-		return starlark.None, os.MkdirAll(src.GoString(), 0755)
+		return starlark.None, GetFS(thread).MkdirAll(src.GoString(), 0755)
 	}
-	return starlark.None, os.Mkdir(src.GoString(), 0755)
+	return starlark.None, GetFS(thread).Mkdir(src.GoString(), 0755)
 }
 
 func fileExists(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
@@ -80,7 +81,7 @@ func fileExists(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 1, &src); err != nil {
 		return nil, err
 	}
-	if _, err := os.Stat(src.GoString()); err != nil {
+	if _, err := GetFS(thread).Stat(src.GoString()); err != nil {
 		return starlark.False, nil
 	}
 	return starlark.True, nil
@@ -91,7 +92,7 @@ func fileRemove(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tupl
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 1, &src); err != nil {
 		return nil, err
 	}
-	return starlark.None, os.RemoveAll(src.GoString())
+	return starlark.None, GetFS(thread).RemoveAll(src.GoString())
 }
 
 func fileList(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
@@ -100,17 +101,19 @@ func fileList(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		return nil, err
 	}
 
-	if st, err := os.Stat(src.GoString()); err == nil && st.IsDir() {
+	fsys := GetFS(thread)
+	if st, err := fsys.Stat(src.GoString()); err == nil && st.IsDir() {
 		src = src + starlark.String("/*")
 	}
-	files, err := filepath.Glob(src.GoString())
+	files, err := Glob(fsys, src.GoString())
 	if err != nil {
 		return nil, err
 	}
 	res := make([]interface{}, 0, len(files))
+	ids := &owners{fsys: fsys}
 	for _, f := range files {
-		abs, _ := filepath.Abs(f)
-		st, err := os.Stat(f)
+		abs, _ := fsys.Abs(f)
+		st, err := fsys.Stat(f)
 		if err != nil {
 			return nil, err
 		}
@@ -121,23 +124,11 @@ func fileList(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		usern := ""
 		group := ""
 		group_name := ""
-		if runtime.GOOS != "windows" {
-			adv, ok := st.Sys().(*syscall.Stat_t)
-			if !ok {
-				break
-			}
-			u, err := user.LookupId(fmt.Sprint(adv.Uid))
-			if err != nil {
-				return nil, err
-			}
-			usern = u.Username
-			g, err := user.LookupGroupId(fmt.Sprint(adv.Uid))
-			if err != nil {
-				return nil, err
-			}
-			group = g.Gid
-			group_name = g.Name
-
+		// Ownership is only known for files on the host
+		if adv, ok := st.Sys().(*syscall.Stat_t); ok && runtime.GOOS != "windows" {
+			usern = ids.user(fmt.Sprint(adv.Uid))
+			group = fmt.Sprint(adv.Gid)
+			group_name = ids.group(group)
 		}
 		fil := map[string]interface{}{
 			"size":          st.Size(),
@@ -155,13 +146,85 @@ func fileList(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 	return ToStarlarkValue(res)
 }
 
+// owners looks up the names of users and groups. Filesystems other than the host, such as an image
+// under a root directory, are looked up in their own /etc/passwd and /etc/group. Ids without a name
+// are returned as they are
+type owners struct {
+	fsys   FS
+	users  map[string]string
+	groups map[string]string
+}
+
+func (o *owners) user(uid string) string {
+	if isHost(o.fsys) {
+		if u, err := user.LookupId(uid); err == nil {
+			return u.Username
+		}
+		return uid
+	}
+	if o.users == nil {
+		o.users = readIDs(o.fsys, "/etc/passwd")
+	}
+	if name, ok := o.users[uid]; ok {
+		return name
+	}
+	return uid
+}
+
+func (o *owners) group(gid string) string {
+	if isHost(o.fsys) {
+		if g, err := user.LookupGroupId(gid); err == nil {
+			return g.Name
+		}
+		return gid
+	}
+	if o.groups == nil {
+		o.groups = readIDs(o.fsys, "/etc/group")
+	}
+	if name, ok := o.groups[gid]; ok {
+		return name
+	}
+	return gid
+}
+
+// readIDs maps the ids in a passwd or group file to their names. Both keep the name in the first
+// field and the id in the third
+func readIDs(fsys FS, name string) map[string]string {
+	ids := make(map[string]string)
+	buf, err := ReadFile(fsys, name)
+	if err != nil {
+		return ids
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if _, ok := ids[fields[2]]; !ok {
+			ids[fields[2]] = fields[0]
+		}
+	}
+	return ids
+}
+
+// isHost reports if the filesystem is the one of the host, where os/user knows the owners
+func isHost(fsys FS) bool {
+	switch f := fsys.(type) {
+	case OSFS, *OSFS:
+		return true
+	case readOnlyFS:
+		return isHost(f.FS)
+	}
+	return false
+}
+
 func fileRead(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var src starlark.String
 	if err := starlark.UnpackPositionalArgs("", args, kwargs, 1, &src); err != nil {
 		return nil, err
 	}
 
-	b, err := os.ReadFile(src.GoString())
+	b, err := ReadFile(GetFS(thread), src.GoString())
 	return starlark.String(string(b)), err
 }
 
@@ -172,7 +235,7 @@ func fileWrite(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 		return nil, err
 	}
 
-	return starlark.None, os.WriteFile(src.GoString(), []byte(contents.GoString()), 0644)
+	return starlark.None, WriteFile(GetFS(thread), src.GoString(), []byte(contents.GoString()), 0644)
 }
 
 // Chmod a file *nix only
@@ -190,29 +253,223 @@ func fileChmod(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 	if !ok {
 		return starlark.None, fmt.Errorf("invalid int: %v", permissions.String())
 	}
-	return starlark.None, os.Chmod(file.GoString(), os.FileMode(perm))
-}
-
-var File = NewModule("file", map[string]Function{
-	"append":      fileAppend,
-	"compress":    nil,
-	"decompress":  nil,
-	"copy":        nil,
-	"exists":      fileExists,
-	"follow":      nil,
-	"is_dir":      fileIsDir,
-	"is_file":     fileIsFile,
-	"list":        fileList,
-	"mkdir":       fileMkDir,
-	"moveto":      fileMoveTo,
-	"parent_dir":  nil,
-	"read":        fileRead,
-	"remove":      fileRemove,
-	"replace":     nil,
-	"replace_all": nil,
-	"template":    nil,
-	"timestomp":   nil,
-	"write":       fileWrite,
-	"find":        nil,
-	"chmod":       fileChmod,
+	return starlark.None, GetFS(thread).Chmod(file.GoString(), os.FileMode(perm))
+}
+
+func fileAppendEffect(args starlark.Tuple) (starlark.Value, string) {
+	return starlark.None, fmt.Sprintf("append %d bytes to %s", len(str(args[1])), str(args[0]))
+}
+
+func fileChmodEffect(args starlark.Tuple) (starlark.Value, string) {
+	perm, _ := starlark.AsInt32(args[1])
+	return starlark.None, fmt.Sprintf("set the permissions of %s to %#o", str(args[0]), perm)
+}
+
+func fileMkDirEffect(args starlark.Tuple) (starlark.Value, string) {
+	if args[1].Truth() {
+		return starlark.None, fmt.Sprintf("create the directory %s and its parents", str(args[0]))
+	}
+	return starlark.None, fmt.Sprintf("create the directory %s", str(args[0]))
+}
+
+func fileMoveToEffect(args starlark.Tuple) (starlark.Value, string) {
+	return starlark.None, fmt.Sprintf("move %s to %s", str(args[0]), str(args[1]))
+}
+
+func fileRemoveEffect(args starlark.Tuple) (starlark.Value, string) {
+	return starlark.None, fmt.Sprintf("remove %s", str(args[0]))
+}
+
+func fileWriteEffect(args starlark.Tuple) (starlark.Value, string) {
+	return starlark.None, fmt.Sprintf("write %d bytes to %s", len(str(args[1])), str(args[0]))
+}
+
+var File = NewModule("file", []Func{
+	{
+		Name: "append",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "content", Type: "string"},
+		},
+		Doc:    "Appends content to a file, creating it if needed",
+		Fn:     fileAppend,
+		Effect: fileAppendEffect,
+	},
+	{
+		Name: "chmod",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "permissions", Type: "int"},
+		},
+		Doc:     "Sets the permission bits of a file",
+		Differs: "Not in Eldritch",
+		Fn:      fileChmod,
+		Effect:  fileChmodEffect,
+	},
+	{
+		Name: "compress",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "copy",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "decompress",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "exists",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+		},
+		Doc: "Returns True if the path exists",
+		Fn:  fileExists,
+	},
+	{
+		Name: "find",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "name", Default: starlark.None},
+			{Name: "file_type", Default: starlark.None},
+			{Name: "permissions", Default: starlark.None},
+			{Name: "modified_time", Default: starlark.None},
+			{Name: "create_time", Default: starlark.None},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "follow",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "fn"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "is_dir",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+		},
+		Doc: "Returns True if the path is a directory",
+		Fn:  fileIsDir,
+	},
+	{
+		Name: "is_file",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+		},
+		Doc: "Returns True if the path is a regular file",
+		Fn:  fileIsFile,
+	},
+	{
+		Name: "list",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+		},
+		Doc: "Lists the files matching a directory or glob",
+		Fn:  fileList,
+	},
+	{
+		Name: "mkdir",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "parent", Type: "bool", Default: starlark.False},
+		},
+		Doc:    "Creates a directory, and its parents if parent is True",
+		Fn:     fileMkDir,
+		Effect: fileMkDirEffect,
+	},
+	{
+		Name: "moveto",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+		},
+		Doc:    "Moves a file or directory",
+		Fn:     fileMoveTo,
+		Effect: fileMoveToEffect,
+	},
+	{
+		Name: "parent_dir",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "read",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+		},
+		Doc: "Returns the contents of a file",
+		Fn:  fileRead,
+	},
+	{
+		Name: "remove",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+		},
+		Doc:    "Removes a file or directory recursively",
+		Fn:     fileRemove,
+		Effect: fileRemoveEffect,
+	},
+	{
+		Name: "replace",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "pattern", Type: "string"},
+			{Name: "value", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "replace_all",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "pattern", Type: "string"},
+			{Name: "value", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "template",
+		Params: []Param{
+			{Name: "template_path", Type: "string"},
+			{Name: "dst", Type: "string"},
+			{Name: "args", Type: "dict"},
+			{Name: "autoescape", Type: "bool"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "timestomp",
+		Params: []Param{
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "write",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "content", Type: "string"},
+		},
+		Doc:    "Writes content to a file, replacing it",
+		Fn:     fileWrite,
+		Effect: fileWriteEffect,
+	},
 })
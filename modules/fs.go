@@ -0,0 +1,227 @@
+package modules
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"go.starlark.net/starlark"
+)
+
+// FS is a writable filesystem that the file module operates on. Names are paths as scripts pass
+// them, in the syntax of the host rather than the slash separated names of io/fs
+type FS interface {
+	Open(name string) (fs.File, error)
+	// OpenFile opens a file with os.OpenFile flags, for reading and writing
+	OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error)
+	Stat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of a directory sorted by name
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+	// Abs returns the absolute form of a path, as it is named within the filesystem
+	Abs(name string) (string, error)
+}
+
+// WritableFile is a file opened from an FS with OpenFile
+type WritableFile interface {
+	fs.File
+	io.Writer
+}
+
+// OSFS is the filesystem of the host
+type OSFS struct{}
+
+func (OSFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (OSFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OSFS) Mkdir(name string, perm fs.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (OSFS) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (OSFS) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (OSFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (OSFS) Abs(name string) (string, error) {
+	return filepath.Abs(name)
+}
+
+// ReadOnly wraps a filesystem so that every change to it fails with EROFS
+func ReadOnly(fsys FS) FS {
+	return readOnlyFS{fsys}
+}
+
+type readOnlyFS struct {
+	FS
+}
+
+func readOnly(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: syscall.EROFS}
+}
+
+func (r readOnlyFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, readOnly("open", name)
+	}
+	return r.FS.OpenFile(name, flag, perm)
+}
+
+func (readOnlyFS) Mkdir(name string, perm fs.FileMode) error {
+	return readOnly("mkdir", name)
+}
+
+func (readOnlyFS) MkdirAll(name string, perm fs.FileMode) error {
+	return readOnly("mkdir", name)
+}
+
+func (readOnlyFS) RemoveAll(name string) error {
+	return readOnly("remove", name)
+}
+
+func (readOnlyFS) Rename(oldname, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EROFS}
+}
+
+func (readOnlyFS) Chmod(name string, mode fs.FileMode) error {
+	return readOnly("chmod", name)
+}
+
+// ReadFile reads a whole file from fsys
+func ReadFile(fsys FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile writes data to a file in fsys, creating or truncating it
+func WriteFile(fsys FS, name string, data []byte, perm fs.FileMode) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Glob returns the names in fsys matching the pattern, with the syntax of filepath.Glob
+func Glob(fsys FS, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if _, err := fsys.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = cleanGlobDir(dir)
+	if !hasMeta(dir) {
+		return globDir(fsys, dir, file, nil), nil
+	}
+	if dir == pattern {
+		return nil, filepath.ErrBadPattern
+	}
+	dirs, err := Glob(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, d := range dirs {
+		res = globDir(fsys, d, file, res)
+	}
+	return res, nil
+}
+
+// globDir appends the entries of dir matching pattern to res
+func globDir(fsys FS, dir, pattern string, res []string) []string {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return res
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if ok, _ := filepath.Match(pattern, e.Name()); ok {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		res = append(res, filepath.Join(dir, n))
+	}
+	return res
+}
+
+func cleanGlobDir(dir string) string {
+	switch dir {
+	case "":
+		return "."
+	case string(filepath.Separator):
+		return dir
+	}
+	return dir[:len(dir)-1]
+}
+
+func hasMeta(path string) bool {
+	magic := `*?[`
+	if filepath.Separator != '\\' {
+		magic = `*?[\`
+	}
+	return strings.ContainsAny(path, magic)
+}
+
+const fsKey = "gnome.fs"
+
+// SetFS sets the filesystem used by the functions called by thread
+func SetFS(thread *starlark.Thread, fsys FS) {
+	thread.SetLocal(fsKey, fsys)
+}
+
+// GetFS returns the filesystem used by the functions called by thread, the host filesystem unless
+// another was set
+func GetFS(thread *starlark.Thread) FS {
+	if fsys, ok := thread.Local(fsKey).(FS); ok && fsys != nil {
+		return fsys
+	}
+	return OSFS{}
+}
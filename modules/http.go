@@ -1,9 +1,35 @@
 package modules
 
+import "go.starlark.net/starlark"
+
 // Implement https://docs.realm.pub/user-guide/eldritch#http
 
-var Http = NewModule("http", map[string]Function{
-	"download": nil,
-	"get":      nil,
-	"post":     nil,
+var Http = NewModule("http", []Func{
+	{
+		Name: "download",
+		Params: []Param{
+			{Name: "uri", Type: "string"},
+			{Name: "dst", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "get",
+		Params: []Param{
+			{Name: "uri", Type: "string"},
+			{Name: "query_params", Default: starlark.None},
+			{Name: "headers", Default: starlark.None},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "post",
+		Params: []Param{
+			{Name: "uri", Type: "string"},
+			{Name: "body", Default: starlark.None},
+			{Name: "form", Default: starlark.None},
+			{Name: "headers", Default: starlark.None},
+		},
+		Doc: "Not implemented",
+	},
 })
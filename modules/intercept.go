@@ -0,0 +1,61 @@
+package modules
+
+import (
+	"go.starlark.net/starlark"
+)
+
+// Call is a call to a builtin, as seen by interceptors
+type Call struct {
+	Thread *starlark.Thread
+	// Module is the name of the module the function belongs to, empty for global builtins such as
+	// exit
+	Module   string
+	Function string
+	// Args and Kwargs are the arguments as passed by the script. Interceptors may replace them
+	// before calling the next handler
+	Args   starlark.Tuple
+	Kwargs []starlark.Tuple
+	// DryRun is set when the function changes the system and the thread is in dry-run mode, so
+	// the result is only a placeholder
+	DryRun bool
+}
+
+// Name returns the full name of the function, such as "file.read"
+func (c *Call) Name() string {
+	if c.Module == "" {
+		return c.Function
+	}
+	return c.Module + "." + c.Function
+}
+
+// Handler performs a call
+type Handler func(call *Call) (starlark.Value, error)
+
+// Interceptor wraps every builtin call. It may inspect or change the call before passing it to
+// next, change the result, or return without calling next at all
+type Interceptor func(call *Call, next Handler) (starlark.Value, error)
+
+const interceptorsKey = "gnome.interceptors"
+
+// SetInterceptors sets the interceptors of the builtins called by thread. The first interceptor
+// sees each call first
+func SetInterceptors(thread *starlark.Thread, interceptors []Interceptor) {
+	thread.SetLocal(interceptorsKey, interceptors)
+}
+
+// GetInterceptors returns the interceptors of the builtins called by thread
+func GetInterceptors(thread *starlark.Thread) []Interceptor {
+	i, _ := thread.Local(interceptorsKey).([]Interceptor)
+	return i
+}
+
+// chain returns a handler running the interceptors in order before h
+func chain(interceptors []Interceptor, h Handler) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		next, intercept := h, interceptors[i]
+		h = func(call *Call) (starlark.Value, error) {
+			return intercept(call, next)
+		}
+	}
+	return h
+}
@@ -0,0 +1,265 @@
+package modules
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// newTestTree fills a filesystem with the tree the tests below expect
+func newTestTree(t *testing.T, fsys FS) FS {
+	t.Helper()
+	for _, d := range []string{"/d/sub", "/empty", "/full/inner"} {
+		if err := fsys.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"/d/a.txt", "/d/b.txt", "/d/c.log", "/d/sub/x.txt", "/f", "/ab"} {
+		if err := WriteFile(fsys, f, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fsys
+}
+
+// testFilesystems returns the filesystems the tests run against, so MemFS is compared to the host
+func testFilesystems(t *testing.T) map[string]func() FS {
+	return map[string]func() FS{
+		"MemFS": func() FS {
+			return newTestTree(t, NewMemFS())
+		},
+		"RootFS": func() FS {
+			r, err := NewRootFS(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return newTestTree(t, r)
+		},
+	}
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		old, new string
+		err      error
+		// exists are checked after the rename
+		exists []string
+	}{
+		{"/f", "/g", nil, []string{"/g"}},
+		{"/f", "/ab", nil, []string{"/ab"}},
+		{"/f", "/f", nil, []string{"/f"}},
+		{"/d", "/moved", nil, []string{"/moved/a.txt", "/moved/sub/x.txt"}},
+		{"/d", "/f", syscall.ENOTDIR, []string{"/d/a.txt", "/f"}},
+		{"/f", "/empty", syscall.EEXIST, []string{"/f", "/empty"}},
+		{"/d", "/empty", syscall.EEXIST, []string{"/d/a.txt", "/empty"}},
+		{"/d", "/full", syscall.EEXIST, []string{"/d", "/full/inner"}},
+		{"/d", "/d/sub/d", syscall.EINVAL, []string{"/d"}},
+		{"/missing", "/g", fs.ErrNotExist, nil},
+		{"/f", "/missing/g", fs.ErrNotExist, []string{"/f"}},
+	}
+	for fsName, newFS := range testFilesystems(t) {
+		for _, tt := range tests {
+			fsys := newFS()
+			err := fsys.Rename(tt.old, tt.new)
+			if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("%s: Rename(%q, %q) = %v, want %v", fsName, tt.old, tt.new, err, tt.err)
+			}
+			for _, name := range tt.exists {
+				if _, err := fsys.Stat(name); err != nil {
+					t.Errorf("%s: after Rename(%q, %q): %v", fsName, tt.old, tt.new, err)
+				}
+			}
+		}
+	}
+}
+
+func TestRemoveAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		removed []string
+		kept    []string
+	}{
+		{"/d", []string{"/d", "/d/a.txt", "/d/sub/x.txt"}, []string{"/f", "/empty"}},
+		{"/a", nil, []string{"/ab"}},
+		{"/d/sub/", []string{"/d/sub"}, []string{"/d/a.txt"}},
+		{"/missing", nil, []string{"/f"}},
+	}
+	for fsName, newFS := range testFilesystems(t) {
+		for _, tt := range tests {
+			fsys := newFS()
+			if err := fsys.RemoveAll(tt.name); err != nil {
+				t.Errorf("%s: RemoveAll(%q) = %v", fsName, tt.name, err)
+			}
+			for _, name := range tt.removed {
+				if _, err := fsys.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("%s: after RemoveAll(%q): %s was not removed", fsName, tt.name, name)
+				}
+			}
+			for _, name := range tt.kept {
+				if _, err := fsys.Stat(name); err != nil {
+					t.Errorf("%s: after RemoveAll(%q): %v", fsName, tt.name, err)
+				}
+			}
+		}
+	}
+
+	// The root itself stays
+	m := newTestTree(t, NewMemFS())
+	if err := m.RemoveAll("/"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := m.ReadDir("/"); err != nil || len(entries) != 0 {
+		t.Errorf("ReadDir(/) after RemoveAll(/) = %v, %v, want an empty root", entries, err)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		flag  int
+		write string
+		want  string
+		err   error
+	}{
+		{"append", "/f", os.O_WRONLY | os.O_APPEND, "+more", "/f+more", nil},
+		{"truncate", "/f", os.O_WRONLY | os.O_TRUNC, "new", "new", nil},
+		{"overwrite", "/f", os.O_WRONLY, "X", "Xf", nil},
+		{"create", "/d/new", os.O_WRONLY | os.O_CREATE, "new", "new", nil},
+		{"exclusive", "/f", os.O_WRONLY | os.O_CREATE | os.O_EXCL, "", "", fs.ErrExist},
+		{"missing", "/missing", os.O_WRONLY, "", "", fs.ErrNotExist},
+		{"missing parent", "/missing/new", os.O_WRONLY | os.O_CREATE, "", "", fs.ErrNotExist},
+		{"parent is a file", "/f/new", os.O_WRONLY | os.O_CREATE, "", "", syscall.ENOTDIR},
+		{"directory", "/d", os.O_WRONLY, "", "", syscall.EISDIR},
+	}
+	for fsName, newFS := range testFilesystems(t) {
+		for _, tt := range tests {
+			fsys := newFS()
+			f, err := fsys.OpenFile(tt.file, tt.flag, 0644)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("%s: %s: OpenFile(%q) = %v, want %v", fsName, tt.name, tt.file, err, tt.err)
+				}
+				if f != nil {
+					f.Close()
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %s: OpenFile(%q) = %v", fsName, tt.name, tt.file, err)
+				continue
+			}
+			_, err = f.Write([]byte(tt.write))
+			f.Close()
+			if err != nil {
+				t.Errorf("%s: %s: Write() = %v", fsName, tt.name, err)
+			}
+			if buf, err := ReadFile(fsys, tt.file); err != nil || string(buf) != tt.want {
+				t.Errorf("%s: %s: ReadFile(%q) = %q, %v, want %q", fsName, tt.name, tt.file, buf, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestMemFileModes(t *testing.T) {
+	m := newTestTree(t, NewMemFS())
+	f, err := m.OpenFile("/f", os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Read() on a write only file = %v, want EBADF", err)
+	}
+	f.Close()
+	if _, err := f.Write([]byte("x")); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("Write() after Close() = %v, want ErrClosed", err)
+	}
+
+	f, err = m.OpenFile("/f", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("x")); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Write() on a read only file = %v, want EBADF", err)
+	}
+	if buf, err := io.ReadAll(f); err != nil || string(buf) != "/f" {
+		t.Errorf("ReadAll() = %q, %v, want %q", buf, err, "/f")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	fsys := ReadOnly(newTestTree(t, NewMemFS()))
+	changes := map[string]func() error{
+		"create": func() error {
+			return WriteFile(fsys, "/new", nil, 0644)
+		},
+		"write": func() error {
+			_, err := fsys.OpenFile("/f", os.O_WRONLY, 0)
+			return err
+		},
+		"append": func() error {
+			_, err := fsys.OpenFile("/f", os.O_RDONLY|os.O_APPEND, 0)
+			return err
+		},
+		"truncate": func() error {
+			_, err := fsys.OpenFile("/f", os.O_RDONLY|os.O_TRUNC, 0)
+			return err
+		},
+		"mkdir":    func() error { return fsys.Mkdir("/new", 0755) },
+		"mkdirall": func() error { return fsys.MkdirAll("/new/dir", 0755) },
+		"remove":   func() error { return fsys.RemoveAll("/d") },
+		"rename":   func() error { return fsys.Rename("/f", "/g") },
+		"chmod":    func() error { return fsys.Chmod("/f", 0600) },
+	}
+	for name, change := range changes {
+		if err := change(); !errors.Is(err, syscall.EROFS) {
+			t.Errorf("%s = %v, want EROFS", name, err)
+		}
+	}
+
+	if buf, err := ReadFile(fsys, "/f"); err != nil || string(buf) != "/f" {
+		t.Errorf("ReadFile() = %q, %v, want %q", buf, err, "/f")
+	}
+	if entries, err := fsys.ReadDir("/d"); err != nil || len(entries) != 4 {
+		t.Errorf("ReadDir() = %v, %v, want 4 entries", entries, err)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		err     error
+	}{
+		{"/d/*.txt", []string{"/d/a.txt", "/d/b.txt"}, nil},
+		{"/d/?.log", []string{"/d/c.log"}, nil},
+		{"/d/[ab].txt", []string{"/d/a.txt", "/d/b.txt"}, nil},
+		{"/*/x.txt", nil, nil},
+		{"/*/*/x.txt", []string{"/d/sub/x.txt"}, nil},
+		{"/d/*", []string{"/d/a.txt", "/d/b.txt", "/d/c.log", "/d/sub"}, nil},
+		{"d/*.log", []string{"d/c.log"}, nil},
+		{"/f", []string{"/f"}, nil},
+		{"/missing", nil, nil},
+		{"/missing/*", nil, nil},
+		{"/d/[", nil, filepath.ErrBadPattern},
+	}
+	for fsName, newFS := range testFilesystems(t) {
+		fsys := newFS()
+		for _, tt := range tests {
+			got, err := Glob(fsys, tt.pattern)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: Glob(%q) error = %v, want %v", fsName, tt.pattern, err, tt.err)
+				continue
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: Glob(%q) = %q, want %q", fsName, tt.pattern, got, tt.want)
+			}
+		}
+	}
+}
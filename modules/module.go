@@ -1,6 +1,7 @@
 package modules
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
@@ -11,17 +12,46 @@ type Function func(thread *starlark.Thread, _ *starlark.Builtin, args starlark.T
 
 type Module starlark.StringDict
 
-func NewModule(name string, funcs map[string]Function) Module {
+// NewModule creates a module from the declarations of its functions
+func NewModule(name string, funcs []Func) Module {
 	m := Module{}
-	for k, f := range funcs {
-		if f == nil {
-			f = notImplemented(name, k)
-		}
-		m[k] = starlark.NewBuiltin(name+"."+k, f)
+	for _, f := range funcs {
+		m[f.Name] = NewBuiltin(name+"."+f.Name, f)
 	}
 	return m
 }
 
+const contextKey = "gnome.context"
+
+// SetContext attaches ctx to the thread so long running builtins can be cancelled with the script
+func SetContext(thread *starlark.Thread, ctx context.Context) {
+	thread.SetLocal(contextKey, ctx)
+}
+
+// Context returns the context attached to the thread, or context.Background if there is none
+func Context(thread *starlark.Thread) context.Context {
+	if ctx, ok := thread.Local(contextKey).(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+const scriptKey = "gnome.script"
+
+// SetScript records the script the thread runs for. Threads running a loaded module keep the
+// script that loaded it
+func SetScript(thread *starlark.Thread, name string) {
+	thread.SetLocal(scriptKey, name)
+}
+
+// Script returns the script the thread runs for, or the name of the thread if it has none
+func Script(thread *starlark.Thread) string {
+	if name, ok := thread.Local(scriptKey).(string); ok {
+		return name
+	}
+	return thread.Name
+}
+
 func (m Module) Hash() (uint32, error) {
 	return 0, fmt.Errorf("library is unhashable")
 }
@@ -142,7 +172,7 @@ func ToGolangValue(val starlark.Value) (interface{}, error) {
 	case starlark.String:
 		return string(v), nil
 	case starlark.Tuple:
-		var list []interface{}
+		list := make([]interface{}, 0, v.Len())
 		iter := v.Iterate()
 		defer iter.Done()
 		var x starlark.Value
@@ -155,7 +185,7 @@ func ToGolangValue(val starlark.Value) (interface{}, error) {
 		}
 		return list, nil
 	case *starlark.List:
-		var list []interface{}
+		list := make([]interface{}, 0, v.Len())
 		iter := v.Iterate()
 		defer iter.Done()
 		var x starlark.Value
@@ -193,9 +223,3 @@ func ToGolangValue(val starlark.Value) (interface{}, error) {
 		return nil, fmt.Errorf("unsupported Starlark type: %v", val.Type())
 	}
 }
-
-func notImplemented(module, name string) Function {
-	return func(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-		return nil, fmt.Errorf("%s.%s not implemented", module, name)
-	}
-}
@@ -1,15 +1,109 @@
 package modules
 
+import "go.starlark.net/starlark"
+
 // Implement https://docs.realm.pub/user-guide/eldritch#http
 
-var Pivot = NewModule("pivot", map[string]Function{
-	"arp_scan":           nil,
-	"bind_proxy":         nil,
-	"ncat":               nil,
-	"port_forward":       nil,
-	"port_scan":          nil,
-	"smb_exec":           nil,
-	"ssh_copy":           nil,
-	"ssh_exec":           nil,
-	"ssh_password_spray": nil,
+var Pivot = NewModule("pivot", []Func{
+	{
+		Name: "arp_scan",
+		Params: []Param{
+			{Name: "target_cidrs", Type: "list"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "bind_proxy",
+		Params: []Param{
+			{Name: "listen_address", Type: "string"},
+			{Name: "listen_port", Type: "int"},
+			{Name: "username", Type: "string"},
+			{Name: "password", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "ncat",
+		Params: []Param{
+			{Name: "address", Type: "string"},
+			{Name: "port", Type: "int"},
+			{Name: "data", Type: "string"},
+			{Name: "protocol", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "port_forward",
+		Params: []Param{
+			{Name: "listen_address", Type: "string"},
+			{Name: "listen_port", Type: "int"},
+			{Name: "forward_address", Type: "string"},
+			{Name: "forward_port", Type: "int"},
+			{Name: "protocol", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "port_scan",
+		Params: []Param{
+			{Name: "target_cidrs", Type: "list"},
+			{Name: "ports", Type: "list"},
+			{Name: "protocol", Type: "string"},
+			{Name: "timeout", Type: "int"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "smb_exec",
+		Params: []Param{
+			{Name: "target", Type: "string"},
+			{Name: "port", Type: "int"},
+			{Name: "username", Type: "string"},
+			{Name: "password", Type: "string"},
+			{Name: "hash", Type: "string"},
+			{Name: "command", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "ssh_copy",
+		Params: []Param{
+			{Name: "target", Type: "string"},
+			{Name: "port", Type: "int"},
+			{Name: "src", Type: "string"},
+			{Name: "dst", Type: "string"},
+			{Name: "username", Type: "string"},
+			{Name: "password", Default: starlark.None},
+			{Name: "key", Default: starlark.None},
+			{Name: "key_password", Default: starlark.None},
+			{Name: "timeout", Default: starlark.None},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "ssh_exec",
+		Params: []Param{
+			{Name: "target", Type: "string"},
+			{Name: "port", Type: "int"},
+			{Name: "command", Type: "string"},
+			{Name: "username", Type: "string"},
+			{Name: "password", Default: starlark.None},
+			{Name: "key", Default: starlark.None},
+			{Name: "key_password", Default: starlark.None},
+			{Name: "timeout", Default: starlark.None},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "ssh_password_spray",
+		Params: []Param{
+			{Name: "targets", Type: "list"},
+			{Name: "port", Type: "int"},
+			{Name: "credentials", Type: "list"},
+			{Name: "keys", Type: "list"},
+			{Name: "command", Type: "string"},
+			{Name: "shell_path", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
 })
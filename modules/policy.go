@@ -0,0 +1,123 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	"go.starlark.net/starlark"
+)
+
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+)
+
+// Policy decides which module functions a script may call. Rules are checked in order and the
+// first matching rule wins, if no rule matches the default action is used
+type Policy struct {
+	// Default is the action when no rule matches, "allow" or "deny". Empty allows
+	Default string       `json:"default"`
+	Rules   []PolicyRule `json:"rules"`
+}
+
+// PolicyRule matches calls by "module.function" glob and optionally by script name glob. Globs use
+// path.Match syntax, for example "sys.*" or "*.remove"
+type PolicyRule struct {
+	Action    string   `json:"action"`
+	Functions []string `json:"functions"`
+	// Scripts limits the rule to the matching scripts. Empty matches every script. Modules loaded by
+	// a script are matched as that script
+	Scripts []string `json:"scripts,omitempty"`
+}
+
+// PolicyError is returned by a builtin that the policy does not allow the script to call
+type PolicyError struct {
+	Script   string
+	Function string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy denies %s in script '%s'", e.Function, e.Script)
+}
+
+// LoadPolicy reads a JSON policy from disk
+func LoadPolicy(filename string) (*Policy, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err := json.Unmarshal(buf, p); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %v", filename, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %v", filename, err)
+	}
+	return p, nil
+}
+
+func (p *Policy) validate() error {
+	if p.Default != "" && p.Default != PolicyAllow && p.Default != PolicyDeny {
+		return fmt.Errorf("invalid default action '%s'", p.Default)
+	}
+	for _, r := range p.Rules {
+		if r.Action != PolicyAllow && r.Action != PolicyDeny {
+			return fmt.Errorf("invalid action '%s'", r.Action)
+		}
+		for _, g := range append(r.Functions, r.Scripts...) {
+			if _, err := path.Match(g, ""); err != nil {
+				return fmt.Errorf("invalid pattern '%s'", g)
+			}
+		}
+	}
+	return nil
+}
+
+// Check returns a *PolicyError if script may not call function
+func (p *Policy) Check(script, function string) error {
+	if p == nil {
+		return nil
+	}
+	action := p.Default
+	for _, r := range p.Rules {
+		if r.matches(script, function) {
+			action = r.Action
+			break
+		}
+	}
+	if action == PolicyDeny {
+		return &PolicyError{Script: script, Function: function}
+	}
+	return nil
+}
+
+func (r PolicyRule) matches(script, function string) bool {
+	if !matchAny(r.Functions, function) {
+		return false
+	}
+	return len(r.Scripts) == 0 || matchAny(r.Scripts, script)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+const policyKey = "gnome.policy"
+
+// SetPolicy sets the policy enforced on the builtins called by the thread
+func SetPolicy(thread *starlark.Thread, p *Policy) {
+	thread.SetLocal(policyKey, p)
+}
+
+// GetPolicy returns the policy of the thread, or nil if everything is allowed
+func GetPolicy(thread *starlark.Thread) *Policy {
+	p, _ := thread.Local(policyKey).(*Policy)
+	return p
+}
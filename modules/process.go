@@ -1,6 +1,7 @@
 package modules
 
 import (
+	"fmt"
 	"os"
 	"syscall"
 
@@ -44,14 +45,48 @@ func processKill(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tup
 		return nil, err
 	}
 	pidActual, _ := pid.Int64()
-	sigActual, _ := pid.Int64()
-	return nil, syscall.Kill(int(pidActual), syscall.Signal(sigActual))
+	sigActual, _ := signal.Int64()
+	if err := syscall.Kill(int(pidActual), syscall.Signal(sigActual)); err != nil {
+		return nil, err
+	}
+	return starlark.None, nil
+}
+
+func processKillEffect(args starlark.Tuple) (starlark.Value, string) {
+	return starlark.None, fmt.Sprintf("send signal %s to process %s", args[1], args[0])
 }
 
-var Process = NewModule("process", map[string]Function{
-	"info":    processInfo,
-	"kill":    processKill,
-	"list":    nil,
-	"name":    nil,
-	"netstat": nil,
+var Process = NewModule("process", []Func{
+	{
+		Name:    "info",
+		Doc:     "Returns information about the current process",
+		Differs: "Only describes the current process and does not take a pid",
+		Fn:      processInfo,
+	},
+	{
+		Name: "kill",
+		Params: []Param{
+			{Name: "pid", Type: "int"},
+			{Name: "signal", Type: "int", Default: starlark.MakeInt(int(syscall.SIGKILL))},
+		},
+		Doc:     "Sends a signal to a process, SIGKILL by default",
+		Differs: "Takes an optional signal to send instead of always sending SIGKILL",
+		Fn:      processKill,
+		Effect:  processKillEffect,
+	},
+	{
+		Name: "list",
+		Doc:  "Not implemented",
+	},
+	{
+		Name: "name",
+		Params: []Param{
+			{Name: "pid", Type: "int"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "netstat",
+		Doc:  "Not implemented",
+	},
 })
@@ -2,9 +2,39 @@ package modules
 
 // Implement https://docs.realm.pub/user-guide/eldritch#regex
 
-var Regex = NewModule("regex", map[string]Function{
-	"match":       nil,
-	"match_all":   nil,
-	"replace":     nil,
-	"replace_all": nil,
+var Regex = NewModule("regex", []Func{
+	{
+		Name: "match",
+		Params: []Param{
+			{Name: "haystack", Type: "string"},
+			{Name: "pattern", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "match_all",
+		Params: []Param{
+			{Name: "haystack", Type: "string"},
+			{Name: "pattern", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "replace",
+		Params: []Param{
+			{Name: "haystack", Type: "string"},
+			{Name: "pattern", Type: "string"},
+			{Name: "value", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "replace_all",
+		Params: []Param{
+			{Name: "haystack", Type: "string"},
+			{Name: "pattern", Type: "string"},
+			{Name: "value", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
 })
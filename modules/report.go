@@ -11,9 +11,33 @@ func report(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 }
 
 // Intentionally not implemented. These functions dont, error, they just return nil
-var Report = NewModule("report", map[string]Function{
-	"match":       report,
-	"match_all":   report,
-	"replace":     report,
-	"replace_all": report,
+var Report = NewModule("report", []Func{
+	{
+		Name:     "match",
+		Variadic: true,
+		Doc:      "Accepts any arguments and does nothing",
+		Differs:  "Not in Eldritch, whose report functions are file, process_list, ssh_key and user_password",
+		Fn:       report,
+	},
+	{
+		Name:     "match_all",
+		Variadic: true,
+		Doc:      "Accepts any arguments and does nothing",
+		Differs:  "Not in Eldritch, whose report functions are file, process_list, ssh_key and user_password",
+		Fn:       report,
+	},
+	{
+		Name:     "replace",
+		Variadic: true,
+		Doc:      "Accepts any arguments and does nothing",
+		Differs:  "Not in Eldritch, whose report functions are file, process_list, ssh_key and user_password",
+		Fn:       report,
+	},
+	{
+		Name:     "replace_all",
+		Variadic: true,
+		Doc:      "Accepts any arguments and does nothing",
+		Differs:  "Not in Eldritch, whose report functions are file, process_list, ssh_key and user_password",
+		Fn:       report,
+	},
 })
@@ -0,0 +1,209 @@
+package modules
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// maxSymlinks limits the symlinks followed while resolving a path, as the kernel does
+const maxSymlinks = 40
+
+// RootFS is the host filesystem seen from a root directory, such as an extracted image. Absolute
+// paths are resolved from the root and relative paths as if the working directory was the root.
+// Neither ".." nor symlinks lead outside of it: symlinks are followed within the root, so an
+// absolute link target is taken relative to the root as it would be on the original machine
+type RootFS struct {
+	root string
+}
+
+// NewRootFS returns the filesystem under root
+func NewRootFS(root string) (*RootFS, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, &fs.PathError{Op: "root", Path: root, Err: syscall.ENOTDIR}
+	}
+	return &RootFS{root: root}, nil
+}
+
+// Root returns the directory of the host that the filesystem is rooted at
+func (r *RootFS) Root() string {
+	return r.root
+}
+
+// resolve returns the host path of name. Symlinks are followed, except for the last element of
+// the path when followLast is false
+func (r *RootFS) resolve(op, name string, followLast bool) (string, error) {
+	// virtual is the resolved path within the root, rest are the elements left to resolve
+	virtual := "/"
+	rest := strings.Split(filepath.ToSlash(name), "/")
+	links := 0
+	for len(rest) > 0 {
+		elem := rest[0]
+		rest = rest[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			virtual = path.Dir(virtual)
+			continue
+		}
+
+		next := path.Join(virtual, elem)
+		if len(rest) == 0 && !followLast {
+			virtual = next
+			break
+		}
+		st, err := os.Lstat(r.host(next))
+		if err != nil || st.Mode()&fs.ModeSymlink == 0 {
+			// Missing elements may be about to be created
+			virtual = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", &fs.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+		}
+		target, err := os.Readlink(r.host(next))
+		if err != nil {
+			return "", &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		target = filepath.ToSlash(target)
+		if path.IsAbs(target) {
+			virtual = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+	return r.host(virtual), nil
+}
+
+// host returns the host path of a resolved slash separated path within the root
+func (r *RootFS) host(virtual string) string {
+	return filepath.Join(r.root, filepath.FromSlash(virtual))
+}
+
+// pathErr reports errors with the path the script used rather than the host path
+func pathErr(err error, name string) error {
+	switch e := err.(type) {
+	case *fs.PathError:
+		return &fs.PathError{Op: e.Op, Path: name, Err: e.Err}
+	case *os.LinkError:
+		return &fs.PathError{Op: e.Op, Path: name, Err: e.Err}
+	}
+	return err
+}
+
+func (r *RootFS) Open(name string) (fs.File, error) {
+	return r.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (r *RootFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	p, err := r.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, pathErr(err, name)
+	}
+	return f, nil
+}
+
+func (r *RootFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := r.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(p)
+	return st, pathErr(err, name)
+}
+
+func (r *RootFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := r.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(p)
+	return entries, pathErr(err, name)
+}
+
+func (r *RootFS) Mkdir(name string, perm fs.FileMode) error {
+	p, err := r.resolve("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.Mkdir(p, perm), name)
+}
+
+func (r *RootFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := r.resolve("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.MkdirAll(p, perm), name)
+}
+
+func (r *RootFS) RemoveAll(name string) error {
+	p, err := r.resolve("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if p == r.root {
+		// Empty the root rather than removing it
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return pathErr(err, name)
+		}
+		for _, e := range entries {
+			if err := os.RemoveAll(filepath.Join(p, e.Name())); err != nil {
+				return pathErr(err, name)
+			}
+		}
+		return nil
+	}
+	return pathErr(os.RemoveAll(p), name)
+}
+
+func (r *RootFS) Rename(oldname, newname string) error {
+	src, err := r.resolve("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+	dst, err := r.resolve("rename", newname, false)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if e, ok := err.(*os.LinkError); ok {
+			return &os.LinkError{Op: e.Op, Old: oldname, New: newname, Err: e.Err}
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *RootFS) Chmod(name string, mode fs.FileMode) error {
+	p, err := r.resolve("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.Chmod(p, mode), name)
+}
+
+// Abs returns the absolute path within the root. Like filepath.Abs, symlinks are not resolved
+func (r *RootFS) Abs(name string) (string, error) {
+	return filepath.FromSlash(path.Clean("/" + filepath.ToSlash(name))), nil
+}
@@ -0,0 +1,181 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+// newTestRoot returns a jail and a directory next to it holding a secret that must stay out of reach
+func newTestRoot(t *testing.T) (*RootFS, string) {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "etc"), outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(root, "secret.txt"):    "inside",
+		filepath.Join(root, "etc", "hosts"):  "127.0.0.1 image",
+		filepath.Join(outside, "secret.txt"): "outside",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"rel":     "../outside",
+		"deeprel": "../../../../../../outside",
+		"abs":     outside,
+		"etclink": "/etc",
+		"loop1":   "loop2",
+		"loop2":   "loop1",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewRootFS(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, outside
+}
+
+func TestRootFSRead(t *testing.T) {
+	r, _ := newTestRoot(t)
+	tests := []struct {
+		name string
+		want string
+		err  error
+	}{
+		{"/secret.txt", "inside", nil},
+		{"secret.txt", "inside", nil},
+		{"../../secret.txt", "inside", nil},
+		{"/etc/../../../secret.txt", "inside", nil},
+		{"/etclink/hosts", "127.0.0.1 image", nil},
+		{"/rel/secret.txt", "", fs.ErrNotExist},
+		{"/deeprel/secret.txt", "", fs.ErrNotExist},
+		{"/abs/secret.txt", "", fs.ErrNotExist},
+		{"/loop1", "", syscall.ELOOP},
+	}
+	for _, tt := range tests {
+		buf, err := ReadFile(r, tt.name)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ReadFile(%q) = %q, %v, want error %v", tt.name, buf, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || string(buf) != tt.want {
+			t.Errorf("ReadFile(%q) = %q, %v, want %q", tt.name, buf, err, tt.want)
+		}
+	}
+}
+
+func TestRootFSWrite(t *testing.T) {
+	r, outside := newTestRoot(t)
+	for _, name := range []string{"/rel/new.txt", "/abs/new.txt", "/../outside/new.txt"} {
+		// Creating the file may fail, it must not land outside of the root
+		WriteFile(r, name, []byte("escaped"), 0644)
+		r.MkdirAll(name+".d", 0755)
+	}
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("files were created outside of the root: %v", entries)
+	}
+
+	if err := r.Rename("/secret.txt", "/../moved.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Root(), "moved.txt")); err != nil {
+		t.Errorf("rename out of the root was not kept inside: %v", err)
+	}
+}
+
+func TestRootFSRemoveAll(t *testing.T) {
+	r, outside := newTestRoot(t)
+	for _, name := range []string{"/rel", "/abs/secret.txt", "/.."} {
+		if err := r.RemoveAll(name); err != nil {
+			t.Errorf("RemoveAll(%q) = %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil {
+		t.Errorf("file outside of the root was removed: %v", err)
+	}
+	if _, err := os.Stat(r.Root()); err != nil {
+		t.Errorf("root was removed: %v", err)
+	}
+}
+
+func TestRootFSAbs(t *testing.T) {
+	r, _ := newTestRoot(t)
+	tests := map[string]string{
+		"secret.txt":       "/secret.txt",
+		"../../etc/hosts":  "/etc/hosts",
+		"/etclink/hosts":   "/etclink/hosts",
+		"/etc/./../rel/..": "/",
+	}
+	for name, want := range tests {
+		if got, err := r.Abs(name); err != nil || got != filepath.FromSlash(want) {
+			t.Errorf("Abs(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestFileListOwners(t *testing.T) {
+	r, _ := newTestRoot(t)
+	passwd := fmt.Sprintf("imageuser:x:%d:%d::/:/bin/sh\n", os.Getuid(), os.Getgid())
+	group := fmt.Sprintf("imagegroup:x:%d:\n", os.Getgid())
+	if err := WriteFile(r, "/etc/passwd", []byte(passwd), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(r, "/etc/group", []byte(group), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	thread := &starlark.Thread{}
+	SetFS(thread, r)
+	v, err := fileList(thread, nil, starlark.Tuple{starlark.String("/secret.txt")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := v.(*starlark.List)
+	if files.Len() != 1 {
+		t.Fatalf("file.list returned %d files, want 1", files.Len())
+	}
+	f := files.Index(0).(*starlark.Dict)
+	for key, want := range map[string]string{"owner": "imageuser", "group_name": "imagegroup"} {
+		got, _, _ := f.Get(starlark.String(key))
+		if got != starlark.String(want) {
+			t.Errorf("%s = %v, want %q", key, got, want)
+		}
+	}
+
+	// Ids missing from the image are reported as they are
+	if err := r.RemoveAll("/etc/passwd"); err != nil {
+		t.Fatal(err)
+	}
+	v, err = fileList(thread, nil, starlark.Tuple{starlark.String("/secret.txt")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner, _, _ := v.(*starlark.List).Index(0).(*starlark.Dict).Get(starlark.String("owner"))
+	if owner != starlark.String(fmt.Sprint(os.Getuid())) {
+		t.Errorf("owner = %v, want %d", owner, os.Getuid())
+	}
+}
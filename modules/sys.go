@@ -2,6 +2,7 @@ package modules
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -31,15 +32,27 @@ func getos() (os string, arch string) {
 		arch = "x86_64"
 	case "386":
 		arch = "i386"
+	case "arm64":
+		arch = "aarch64"
 	default:
-		arch = runtime.GOOS
+		arch = runtime.GOARCH
 	}
 	return
 }
 
-func run(cmd string, args []string, disown bool) (starlark.Value, error) {
+// GetOS returns the platform and architecture as reported by sys.get_os, e.g. PLATFORM_LINUX and
+// x86_64
+func GetOS() (platform string, arch string) {
+	return getos()
+}
+
+func run(ctx context.Context, cmd string, args []string, disown bool) (starlark.Value, error) {
 	var stdout, stderr bytes.Buffer
-	c := exec.Command(cmd, args...)
+	c := exec.CommandContext(ctx, cmd, args...)
+	if disown {
+		// Disowned processes outlive the script, dont tie them to its context
+		c = exec.Command(cmd, args...)
+	}
 	cwd, err := os.Getwd()
 	if err != nil {
 		return nil, err
@@ -87,7 +100,7 @@ func SysExec(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		argsActual = append(argsActual, i)
 	}
 
-	return run(cmd.GoString(), argsActual, bool(disown))
+	return run(Context(thread), cmd.GoString(), argsActual, bool(disown))
 }
 
 func SysShell(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
@@ -96,9 +109,9 @@ func SysShell(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		return nil, err
 	}
 	if runtime.GOOS == "windows" {
-		return run("cmd.exe", []string{"/c", cmd.GoString()}, false)
+		return run(Context(thread), "cmd.exe", []string{"/c", cmd.GoString()}, false)
 	}
-	return run("/bin/bash", []string{"-c", cmd.GoString()}, false)
+	return run(Context(thread), "/bin/bash", []string{"-c", cmd.GoString()}, false)
 }
 
 // Implement https://docs.realm.pub/user-guide/eldritch#sys
@@ -266,25 +279,171 @@ func SysGetIp(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 	return ToStarlarkValue(res)
 }
 
+// emptyRun is the placeholder result of a command during a dry run
+func emptyRun() starlark.Value {
+	d := starlark.NewDict(3)
+	d.SetKey(starlark.String("stdout"), starlark.String(""))
+	d.SetKey(starlark.String("stderr"), starlark.String(""))
+	d.SetKey(starlark.String("status"), starlark.MakeInt(0))
+	return d
+}
+
+func sysExecEffect(args starlark.Tuple) (starlark.Value, string) {
+	if args[2].Truth() {
+		return starlark.None, fmt.Sprintf("start %s with arguments %s in the background", str(args[0]), args[1])
+	}
+	return emptyRun(), fmt.Sprintf("run %s with arguments %s", str(args[0]), args[1])
+}
+
+func sysSetEnvEffect(args starlark.Tuple) (starlark.Value, string) {
+	return starlark.None, fmt.Sprintf("set the environment variable %s", str(args[0]))
+}
+
+func sysShellEffect(args starlark.Tuple) (starlark.Value, string) {
+	return emptyRun(), fmt.Sprintf("run %s in a shell", args[0])
+}
+
 // Intentionally not implemented. These functions dont, error, they just return nil
-var Sys = NewModule("sys", map[string]Function{
-	"dll_inject":    nil,
-	"dll_reflect":   nil,
-	"exec":          SysExec,
-	"get_env":       SysGetEnv,
-	"set_env":       SysSetEnv,
-	"get_ip":        SysGetIp,
-	"get_os":        SysGetOs,
-	"get_pid":       SysGetPid,
-	"get_reg":       nil,
-	"get_user":      SysGetUser,
-	"hostname":      SysHostname,
-	"is_windows":    SysIsWindows,
-	"is_linux":      SysIsLinux,
-	"is_bsd":        SysIsBSD,
-	"is_macos":      SysIsMacos,
-	"shell":         SysShell,
-	"write_reg_hex": nil,
-	"write_reg_int": nil,
-	"write_reg_str": nil,
+var Sys = NewModule("sys", []Func{
+	{
+		Name: "dll_inject",
+		Params: []Param{
+			{Name: "dll_path", Type: "string"},
+			{Name: "pid", Type: "int"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "dll_reflect",
+		Params: []Param{
+			{Name: "dll_bytes", Type: "list"},
+			{Name: "pid", Type: "int"},
+			{Name: "function_name", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "exec",
+		Params: []Param{
+			{Name: "path", Type: "string"},
+			{Name: "args", Type: "list"},
+			{Name: "disown", Type: "bool", Default: starlark.False},
+		},
+		Doc:     "Runs a program and returns its stdout, stderr and status",
+		Differs: "Does not take env_vars",
+		Fn:      SysExec,
+		Effect:  sysExecEffect,
+	},
+	{
+		Name: "get_env",
+		Doc:  "Returns the environment as a dict",
+		Fn:   SysGetEnv,
+	},
+	{
+		Name: "get_ip",
+		Doc:  "Lists the network interfaces and their addresses",
+		Fn:   SysGetIp,
+	},
+	{
+		Name: "get_os",
+		Doc:  "Returns the platform, arch and distribution",
+		Fn:   SysGetOs,
+	},
+	{
+		Name: "get_pid",
+		Doc:  "Returns the pid of the current process",
+		Fn:   SysGetPid,
+	},
+	{
+		Name: "get_reg",
+		Params: []Param{
+			{Name: "reghive", Type: "string"},
+			{Name: "regpath", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name:    "get_user",
+		Doc:     "Returns the current user and effective user",
+		Differs: "Also returns groups and group_ids",
+		Fn:      SysGetUser,
+	},
+	{
+		Name: "hostname",
+		Doc:  "Returns the hostname",
+		Fn:   SysHostname,
+	},
+	{
+		Name: "is_bsd",
+		Doc:  "Returns True on BSD",
+		Fn:   SysIsBSD,
+	},
+	{
+		Name: "is_linux",
+		Doc:  "Returns True on Linux",
+		Fn:   SysIsLinux,
+	},
+	{
+		Name: "is_macos",
+		Doc:  "Returns True on macOS",
+		Fn:   SysIsMacos,
+	},
+	{
+		Name: "is_windows",
+		Doc:  "Returns True on Windows",
+		Fn:   SysIsWindows,
+	},
+	{
+		Name: "set_env",
+		Params: []Param{
+			{Name: "key", Type: "string"},
+			{Name: "value", Type: "string"},
+		},
+		Doc:     "Sets an environment variable",
+		Differs: "Not in Eldritch",
+		Fn:      SysSetEnv,
+		Effect:  sysSetEnvEffect,
+	},
+	{
+		Name: "shell",
+		Params: []Param{
+			{Name: "cmd", Type: "string"},
+		},
+		Doc:    "Runs a command through the shell and returns its stdout, stderr and status",
+		Fn:     SysShell,
+		Effect: sysShellEffect,
+	},
+	{
+		Name: "write_reg_hex",
+		Params: []Param{
+			{Name: "reghive", Type: "string"},
+			{Name: "regpath", Type: "string"},
+			{Name: "regname", Type: "string"},
+			{Name: "regtype", Type: "string"},
+			{Name: "regvalue", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "write_reg_int",
+		Params: []Param{
+			{Name: "reghive", Type: "string"},
+			{Name: "regpath", Type: "string"},
+			{Name: "regname", Type: "string"},
+			{Name: "regtype", Type: "string"},
+			{Name: "regvalue", Type: "int"},
+		},
+		Doc: "Not implemented",
+	},
+	{
+		Name: "write_reg_str",
+		Params: []Param{
+			{Name: "reghive", Type: "string"},
+			{Name: "regpath", Type: "string"},
+			{Name: "regname", Type: "string"},
+			{Name: "regtype", Type: "string"},
+			{Name: "regvalue", Type: "string"},
+		},
+		Doc: "Not implemented",
+	},
 })
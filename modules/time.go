@@ -47,14 +47,47 @@ func timeSleep(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 		return nil, err
 	}
 	i, _ := input.Int64()
-	time.Sleep(time.Second * time.Duration(i))
+	t := time.NewTimer(time.Second * time.Duration(i))
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-Context(thread).Done():
+		// The script is being cancelled, the interpreter reports the reason
+	}
 	return starlark.None, nil
 }
 
 // Intentionally not implemented. These functions dont, error, they just return nil
-var Time = NewModule("time", map[string]Function{
-	"format_to_readable": timeFormatToReadable,
-	"format_to_epoch":    timeFormatToEpoch,
-	"now":                timeNow,
-	"sleep":              timeSleep,
+var Time = NewModule("time", []Func{
+	{
+		Name: "format_to_epoch",
+		Params: []Param{
+			{Name: "input", Type: "string"},
+			{Name: "format", Type: "string"},
+		},
+		Doc: "Parses a time with a strftime format and returns the epoch seconds",
+		Fn:  timeFormatToEpoch,
+	},
+	{
+		Name: "format_to_readable",
+		Params: []Param{
+			{Name: "input", Type: "int"},
+			{Name: "format", Type: "string"},
+		},
+		Doc: "Formats epoch seconds with a strftime format",
+		Fn:  timeFormatToReadable,
+	},
+	{
+		Name: "now",
+		Doc:  "Returns the current epoch seconds",
+		Fn:   timeNow,
+	},
+	{
+		Name: "sleep",
+		Params: []Param{
+			{Name: "secs", Type: "int"},
+		},
+		Doc: "Sleeps for the given number of seconds",
+		Fn:  timeSleep,
+	},
 })
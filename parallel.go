@@ -0,0 +1,141 @@
+package gnome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"go.starlark.net/starlark"
+)
+
+// runParallel runs the scripts in waves. Each wave holds every script whose dependencies have
+// finished, and its scripts run concurrently with a frozen copy of the globals exported so far.
+// Results, errors and globals are handled in script order so the outcome does not depend on timing
+func (i *Interpreter) runParallel(ctx context.Context, sess *session, scripts []script, errorHandler func(script string, err error) error) ([]ScriptResult, error) {
+	workers := sess.opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]*ScriptResult, len(scripts))
+	exported := make([]*exports, len(scripts))
+	finished := make(map[string]bool, len(scripts))
+	completed := make(map[string]bool, len(scripts))
+	globals := starlark.StringDict{}
+	collect := func() []ScriptResult {
+		res := make([]ScriptResult, 0, len(scripts))
+		for _, r := range results {
+			if r != nil {
+				res = append(res, *r)
+			}
+		}
+		return res
+	}
+
+	for len(finished) < len(scripts) {
+		if err := ctx.Err(); err != nil {
+			return collect(), err
+		}
+
+		wave := make([]int, 0, len(scripts))
+		for idx, s := range scripts {
+			if results[idx] == nil && dependenciesFinished(s, finished) {
+				wave = append(wave, idx)
+			}
+		}
+		if len(wave) == 0 {
+			// Unreachable as long as the manifest has no cycles
+			break
+		}
+
+		snapshot := make(starlark.StringDict, len(globals))
+		for k, v := range globals {
+			snapshot[k] = v
+		}
+		snapshot.Freeze()
+
+		// exit() in a script stops the other scripts of its wave
+		waveCtx, cancel := context.WithCancel(ctx)
+		sem := make(chan struct{}, workers)
+		var wg sync.WaitGroup
+		for _, idx := range wave {
+			s := scripts[idx]
+			if res := s.prepare(completed); res != nil {
+				results[idx] = res
+				continue
+			}
+			sem <- struct{}{}
+			if waveCtx.Err() != nil {
+				<-sem
+				break
+			}
+			wg.Add(1)
+			go func(idx int, s script) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[idx], exported[idx] = i.exec(waveCtx, sess, s, snapshot)
+				if results[idx].Exit {
+					cancel()
+				}
+			}(idx, s)
+		}
+		wg.Wait()
+		// The scripts stopped by an exit() are not errors of their own, the exit is reported instead
+		stopped := make(map[int]bool)
+		if waveCtx.Err() != nil && ctx.Err() == nil {
+			stoppedBy := ""
+			for _, idx := range wave {
+				if res := results[idx]; res != nil && res.Exit {
+					stoppedBy = res.Name
+					break
+				}
+			}
+			for _, idx := range wave {
+				if res := results[idx]; stoppedBy != "" && res != nil && !res.Exit && errors.Is(res.Err, context.Canceled) {
+					res.Err = fmt.Errorf("stopped by exit() in '%s'", stoppedBy)
+					stopped[idx] = true
+				}
+			}
+		}
+		cancel()
+
+		for _, idx := range wave {
+			res := results[idx]
+			if res == nil {
+				// Never started because of an exit() in the wave
+				continue
+			}
+			finished[res.Name] = true
+			completed[res.Name] = res.Err == nil && res.Skipped == ""
+			if res.Exit {
+				// On exit calls, no other scripts run
+				return collect(), &ExitError{Code: res.ExitCode}
+			}
+			if stopped[idx] {
+				continue
+			}
+			if res.Err != nil {
+				// A failed script resets the globals, as it does when running in order
+				globals = starlark.StringDict{}
+				if err := errorHandler(res.Name, res.Err); err != nil {
+					return collect(), err
+				}
+				continue
+			}
+			sess.commit(globals, exported[idx])
+		}
+	}
+	// The last wave may have been cancelled too
+	return collect(), ctx.Err()
+}
+
+func dependenciesFinished(s script, finished map[string]bool) bool {
+	for _, dep := range s.dependsOn {
+		if !finished[dep] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package gnome
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParallelOutput(t *testing.T) {
+	// Let the scripts interleave even on a single CPU, for the race detector
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	dir := t.TempDir()
+	scripts := make([]string, 0, 8)
+	for n := 0; n < 8; n++ {
+		name := filepath.Join(dir, fmt.Sprintf("script%d.eldr", n))
+		if err := os.WriteFile(name, []byte("for i in range(50):\n    print(i)\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		scripts = append(scripts, name)
+	}
+
+	var out bytes.Buffer
+	interp := NewInterpreter()
+	interp.Output = &out
+	interp.Options.Parallel = true
+	interp.Options.Workers = 4
+	results, err := interp.Run(context.Background(), scripts, func(script string, err error) error {
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(scripts) {
+		t.Fatalf("got %d results, want %d", len(results), len(scripts))
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 8*50 {
+		t.Errorf("got %d lines of output, want %d", lines, 8*50)
+	}
+}
+
+func TestParallelExit(t *testing.T) {
+	dir := t.TempDir()
+	sources := []string{
+		"time.sleep(1)\nexit(3)\n",
+		"while True:\n    time.sleep(1)\n",
+		"while True:\n    time.sleep(1)\n",
+	}
+	scripts := make([]string, 0, len(sources))
+	for n, src := range sources {
+		name := filepath.Join(dir, fmt.Sprintf("script%d.eldr", n))
+		if err := os.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		scripts = append(scripts, name)
+	}
+
+	interp := NewInterpreter()
+	interp.Options.Parallel = true
+	interp.Options.Workers = len(scripts)
+	results, err := interp.Run(context.Background(), scripts, func(script string, err error) error {
+		t.Errorf("error handler called for %s: %v", script, err)
+		return nil
+	})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("Run() error = %v, want exit code 3", err)
+	}
+	if len(results) != len(scripts) {
+		t.Fatalf("got %d results, want %d", len(results), len(scripts))
+	}
+	want := fmt.Sprintf("stopped by exit() in '%s'", scripts[0])
+	for _, r := range results[1:] {
+		if r.Err == nil || r.Err.Error() != want {
+			t.Errorf("%s: error = %v, want %q", r.Name, r.Err, want)
+		}
+	}
+}
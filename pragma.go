@@ -0,0 +1,130 @@
+package gnome
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nullmonk/gnome/modules"
+)
+
+// pragmaPrefix starts a comment in the leading comment block of a script that declares the
+// conditions for running it, e.g. "# gnome: platform=linux arch=x86_64 timeout=30s requires=root"
+const pragmaPrefix = "gnome:"
+
+// pragma holds the conditions declared by a script. Lists are comma separated in the script
+type pragma struct {
+	platforms []string
+	archs     []string
+	requires  []string
+	timeout   time.Duration
+}
+
+// platformNames maps the short platform names used in pragmas to the names used by sys.get_os
+var platformNames = map[string]string{
+	"linux":   "PLATFORM_LINUX",
+	"windows": "PLATFORM_WINDOWS",
+	"macos":   "PLATFORM_MACOS",
+	"darwin":  "PLATFORM_MACOS",
+	"bsd":     "PLATFORM_BSD",
+}
+
+// archNames maps the Go names of architectures to the names used by sys.get_os
+var archNames = map[string]string{
+	"amd64": "x86_64",
+	"386":   "i386",
+	"arm64": "aarch64",
+}
+
+// parsePragma reads the pragmas from the comments at the top of the script. Parsing stops at the
+// first line that is not a comment or blank
+func parsePragma(src []byte) (*pragma, error) {
+	p := &pragma{}
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if !strings.HasPrefix(line, pragmaPrefix) {
+			continue
+		}
+
+		for _, field := range strings.Fields(strings.TrimPrefix(line, pragmaPrefix)) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || value == "" {
+				return nil, fmt.Errorf("invalid pragma '%s'", field)
+			}
+			values := strings.Split(value, ",")
+			switch key {
+			case "platform":
+				for _, v := range values {
+					if name, ok := platformNames[strings.ToLower(v)]; ok {
+						v = name
+					}
+					p.platforms = append(p.platforms, v)
+				}
+			case "arch":
+				for _, v := range values {
+					if name, ok := archNames[strings.ToLower(v)]; ok {
+						v = name
+					}
+					p.archs = append(p.archs, v)
+				}
+			case "requires":
+				for _, v := range values {
+					if v != "root" {
+						return nil, fmt.Errorf("unknown requirement '%s'", v)
+					}
+				}
+				p.requires = append(p.requires, values...)
+			case "timeout":
+				t, err := time.ParseDuration(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid timeout '%s'", value)
+				}
+				p.timeout = t
+			default:
+				return nil, fmt.Errorf("unknown pragma '%s'", key)
+			}
+		}
+	}
+	return p, scanner.Err()
+}
+
+// pragmaBlock returns the comments at the top of the script, where its pragmas are
+func pragmaBlock(src []byte) []byte {
+	rest := src
+	for len(rest) > 0 {
+		line, next, _ := bytes.Cut(rest, []byte("\n"))
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && trimmed[0] != '#' {
+			break
+		}
+		rest = next
+	}
+	return src[:len(src)-len(rest)]
+}
+
+// skip returns the reason the script cannot run on this host, or an empty string if it can
+func (p *pragma) skip() string {
+	platform, arch := modules.GetOS()
+	if len(p.platforms) > 0 && !contains(p.platforms, platform) {
+		return "requires platform " + strings.Join(p.platforms, " or ")
+	}
+	if len(p.archs) > 0 && !contains(p.archs, arch) {
+		return "requires arch " + strings.Join(p.archs, " or ")
+	}
+	for _, r := range p.requires {
+		if r == "root" && os.Geteuid() != 0 {
+			return "requires root"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package gnome
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nullmonk/gnome/modules"
+	"go.starlark.net/starlark"
+)
+
+// ErrNameInUse is returned when registering a module or builtin under a name that is already taken
+var ErrNameInUse = errors.New("name already in use")
+
+// testModules returns the modules that only tests run by Interpreter.Test have
+func testModules() starlark.StringDict {
+	return starlark.StringDict{
+		"assert": &modules.Assert,
+	}
+}
+
+// builtinModules returns the modules and builtins that every interpreter starts with
+func builtinModules() starlark.StringDict {
+	return starlark.StringDict{
+		"assets":  &modules.Assets,
+		"crypto":  &modules.Crypto,
+		"file":    &modules.File,
+		"http":    &modules.Http,
+		"pivot":   &modules.Pivot,
+		"process": &modules.Process,
+		"regex":   &modules.Regex,
+		"report":  &modules.Report,
+		"sys":     &modules.Sys,
+		"time":    &modules.Time,
+		"exit": modules.NewBuiltin("exit", modules.Func{
+			Name:    "exit",
+			Params:  []modules.Param{{Name: "code", Type: "int", Default: starlark.MakeInt(0)}},
+			Doc:     "Stops all scripts and exits with the given status code",
+			Differs: "Not in Eldritch",
+			Fn:      exit,
+		}),
+		"quit": modules.NewBuiltin("quit", modules.Func{
+			Name:    "quit",
+			Doc:     "Stops the current script. The scripts after it still run",
+			Differs: "Not in Eldritch",
+			Fn:      quit,
+		}),
+		"fallback": modules.NewBuiltin("fallback", modules.Func{
+			Name: "fallback",
+			Params: []modules.Param{
+				{Name: "path", Type: "string"},
+				{Name: "args", Type: "list", Default: emptyList},
+			},
+			Doc:     "Replaces the process with a program from the asset locker or the system",
+			Differs: "Not in Eldritch",
+			Fn:      fallback,
+			Effect:  fallbackEffect,
+		}),
+		"try_call": modules.NewBuiltin("try_call", modules.Func{
+			Name:     "try_call",
+			Variadic: true,
+			Doc:      "Calls fn with the remaining arguments, returning (value, None), or (None, error) if it fails. The error has code, function, path and message attributes",
+			Differs:  "Not in Eldritch",
+			Fn:       tryCall,
+		}),
+		"export": modules.NewBuiltin("export", modules.Func{
+			Name:     "export",
+			Variadic: true,
+			Doc:      "Passes values by name to the scripts after this one, as attributes of shared",
+			Differs:  "Not in Eldritch",
+			Fn:       export,
+		}),
+	}
+}
+
+// emptyList is the frozen default of list parameters
+var emptyList = func() *starlark.List {
+	l := starlark.NewList(nil)
+	l.Freeze()
+	return l
+}()
+
+// registered holds the modules and builtins registered for all new interpreters
+var registered = struct {
+	sync.Mutex
+	values starlark.StringDict
+}{values: starlark.StringDict{}}
+
+// RegisterModule makes a module available to the default interpreter and every interpreter
+// created afterwards. The name may not collide with a gnome module or a starlark builtin
+func RegisterModule(name string, m modules.Module) error {
+	return register(name, m, false)
+}
+
+// RegisterBuiltin makes a global function available to the default interpreter and every
+// interpreter created afterwards. The function receives its arguments unchecked, as with a
+// variadic modules.Func
+func RegisterBuiltin(name string, fn modules.Function) error {
+	return register(name, variadic(name, fn), false)
+}
+
+// variadic wraps a function that unpacks its own arguments
+func variadic(name string, fn modules.Function) *modules.Builtin {
+	return modules.NewBuiltin(name, modules.Func{Name: name, Variadic: true, Fn: fn})
+}
+
+// ReplaceModule swaps one of the gnome modules for a custom implementation in the default
+// interpreter and every interpreter created afterwards
+func ReplaceModule(name string, m modules.Module) error {
+	return register(name, m, true)
+}
+
+func register(name string, v starlark.Value, replace bool) error {
+	registered.Lock()
+	defer registered.Unlock()
+	if err := checkName(name, defaultInterpreter.modules, replace); err != nil {
+		return err
+	}
+	registered.values[name] = v
+	defaultInterpreter.modules[name] = v
+	return nil
+}
+
+// RegisterModule makes a module available to the scripts run by this interpreter
+func (i *Interpreter) RegisterModule(name string, m modules.Module) error {
+	return i.register(name, m, false)
+}
+
+// RegisterBuiltin makes a global function available to the scripts run by this interpreter
+func (i *Interpreter) RegisterBuiltin(name string, fn modules.Function) error {
+	return i.register(name, variadic(name, fn), false)
+}
+
+// ReplaceModule swaps one of the gnome modules for a custom implementation in this interpreter
+func (i *Interpreter) ReplaceModule(name string, m modules.Module) error {
+	return i.register(name, m, true)
+}
+
+func (i *Interpreter) register(name string, v starlark.Value, replace bool) error {
+	if err := checkName(name, i.modules, replace); err != nil {
+		return err
+	}
+	i.modules[name] = v
+	return nil
+}
+
+// checkName validates a name for registration. Replacing is only allowed for the gnome modules,
+// everything else must use a free name
+func checkName(name string, current starlark.StringDict, replace bool) error {
+	_, builtin := builtinModules()[name]
+	if replace {
+		if !builtin {
+			return fmt.Errorf("cannot replace '%s': not a gnome module", name)
+		}
+		return nil
+	}
+	if builtin || name == sharedName || current.Has(name) || starlark.Universe.Has(name) {
+		return fmt.Errorf("cannot register '%s': %w", name, ErrNameInUse)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package gnome
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+const (
+	SourceAsset = "asset"
+	SourceDisk  = "disk"
+)
+
+// ScriptResult describes the execution of a single script
+type ScriptResult struct {
+	Name string `json:"name"`
+	// Source is where the script was loaded from, SourceAsset or SourceDisk
+	Source   string        `json:"source"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	// Output is everything the script printed
+	Output string `json:"output"`
+	// Skipped is the reason the script did not run, if it was skipped
+	Skipped string `json:"skipped,omitempty"`
+	// Globals are the global variables exported by the script that have a go representation
+	Globals map[string]interface{} `json:"globals,omitempty"`
+	// Quit is set if the script stopped itself with quit()
+	Quit bool `json:"quit,omitempty"`
+	// Exit is set if the script stopped the interpreter with exit()
+	Exit     bool `json:"exit,omitempty"`
+	ExitCode int  `json:"exit_code,omitempty"`
+	// Warnings are problems with the script that did not stop it
+	Warnings []string `json:"warnings,omitempty"`
+	// Err is the error that stopped the script, if any
+	Err       error  `json:"-"`
+	Backtrace string `json:"backtrace,omitempty"`
+}
+
+// MarshalJSON encodes the result with the error as a string
+func (r ScriptResult) MarshalJSON() ([]byte, error) {
+	type result ScriptResult
+	var msg string
+	if r.Err != nil {
+		msg = r.Err.Error()
+	}
+	return json.Marshal(struct {
+		result
+		Error string `json:"error,omitempty"`
+	}{result(r), msg})
+}
+
+// setErr records the error, along with its backtrace if it came from starlark
+func (r *ScriptResult) setErr(err error) {
+	r.Err = err
+	var e *starlark.EvalError
+	if errors.As(err, &e) {
+		r.Backtrace = e.Backtrace()
+	}
+}
@@ -1,10 +1,14 @@
 package gnome
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/nullmonk/gnome/modules"
 	"go.starlark.net/starlark"
@@ -12,122 +16,401 @@ import (
 )
 
 type script struct {
-	name string
-	src  interface{}
+	name   string
+	src    interface{}
+	source string
+	// compiled is the bytecode of the script, if it has been compiled
+	compiled []byte
+	// Set by the manifest
+	timeout   time.Duration
+	dependsOn []string
+	skip      string
 }
 
-func SetAssetLocker(f fs.FS) {
-	modules.SetAssetLocker(f)
+// RunOptions control the limits applied to a run
+type RunOptions struct {
+	// Timeout limits the execution time of all the scripts together. Zero is no limit
+	Timeout time.Duration
+	// ScriptTimeout limits the execution time of each script. Zero is no limit
+	ScriptTimeout time.Duration
+	// ScriptTimeouts overrides ScriptTimeout for specific scripts, keyed by script name
+	ScriptTimeouts map[string]time.Duration
+	// Parallel runs scripts concurrently as soon as the scripts they depend on have finished. The
+	// globals passed between scripts are frozen
+	Parallel bool
+	// Workers limits the scripts running at once in parallel mode. Defaults to the number of CPUs
+	Workers int
+	// ExplicitExports stops the globals of a script from being passed to the scripts after it.
+	// Only the values passed to export() are shared, as attributes of the shared global
+	ExplicitExports bool
+	// DryRun reports the changes functions such as file.write and sys.exec would make, through the
+	// print output, instead of making them. They return placeholder results
+	DryRun bool
 }
 
-// Run a stark script, passing in the previous globals if specified
-func Run(scripts []string, errorHandler func(script string, err error) error) error {
-	// Set the asset locker to whatever we have specified
-	assets := modules.GetAssetLocker()
-	scripts_to_run := make([]script, 0, 1)
-	if assets != nil {
-		err := fs.WalkDir(assets, ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if !strings.HasSuffix(path, ".eldr") && !strings.HasSuffix(path, ".eldritch") {
-				return nil
-			}
-			if d.IsDir() {
-				return nil
-			}
-			buf, err := fs.ReadFile(assets, path)
-			if err != nil {
-				return nil
-			}
-			scripts_to_run = append(scripts_to_run, script{path, buf})
+// session is the state shared by the scripts of a single run
+type session struct {
+	opts   *RunOptions
+	load   *loader
+	shared *namespace
+}
+
+// commit passes the exports of a script on to the scripts after it
+func (sess *session) commit(globals starlark.StringDict, e *exports) {
+	for k, v := range e.globals {
+		globals[k] = v
+	}
+	sess.shared.commit(e.shared)
+}
+
+// diskScript returns a script from the filesystem. Compiled scripts pick up their source if it is
+// next to them
+func diskScript(name string) script {
+	s := script{name: name, source: SourceDisk}
+	if strings.HasSuffix(name, CompiledExt) {
+		// Errors are left to the interpreter
+		s.compiled, _ = os.ReadFile(name)
+		if buf := readSource(os.ReadFile, name); buf != nil {
+			s.src = buf
+		}
+	}
+	return s
+}
+
+// applyPragma reads the pragmas of the script, loading it from disk if needed, and applies them.
+// Compiled scripts without a source use the pragmas kept with the bytecode
+func (s *script) applyPragma() error {
+	src, ok := s.src.([]byte)
+	if s.compiled != nil && s.src == nil {
+		h, _, err := decodeCompiled(s.compiled)
+		if err != nil {
+			// Leave the error to the interpreter
 			return nil
-		})
+		}
+		src, ok = []byte(h.Pragma), true
+	}
+	if !ok {
+		buf, err := os.ReadFile(s.name)
 		if err != nil {
-			if err := errorHandler("", fmt.Errorf("failed loading script from assets: %v", err)); err != nil {
-				return err
-			}
+			// Leave the error to the interpreter
+			return nil
 		}
+		s.src, src = buf, buf
+	}
+	p, err := parsePragma(src)
+	if err != nil {
+		return fmt.Errorf("invalid pragma in '%s': %v", s.name, err)
+	}
+	s.skip = p.skip()
+	if s.timeout == 0 {
+		s.timeout = p.timeout
+	}
+	return nil
+}
+
+// timeout returns the timeout of the script. The options take precedence over the manifest
+func (o *RunOptions) timeout(s script) time.Duration {
+	if o == nil {
+		return s.timeout
+	}
+	if t, ok := o.ScriptTimeouts[s.name]; ok {
+		return t
+	}
+	if s.timeout > 0 {
+		return s.timeout
+	}
+	return o.ScriptTimeout
+}
+
+// TimeoutError is passed to the error handler when a script runs past its deadline
+type TimeoutError struct {
+	Script  string
+	Elapsed time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("script '%s' timed out after %s", e.Script, e.Elapsed.Round(time.Millisecond))
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Run the scripts with the default interpreter
+func Run(scripts []string, errorHandler func(script string, err error) error) ([]ScriptResult, error) {
+	return RunContext(context.Background(), scripts, errorHandler, nil)
+}
+
+// RunContext is like Run, but stops executing scripts once ctx is done. Timeouts are reported to
+// the error handler as a *TimeoutError, any other cancellation of ctx is returned
+func RunContext(ctx context.Context, scripts []string, errorHandler func(script string, err error) error, opts *RunOptions) ([]ScriptResult, error) {
+	return defaultInterpreter.run(ctx, scripts, errorHandler, opts)
+}
+
+// Run a stark script, passing in the previous globals if specified. A result is returned for every
+// script that was started. If a script calls exit(), no further scripts run and an *ExitError is
+// returned
+func (i *Interpreter) run(ctx context.Context, scripts []string, errorHandler func(script string, err error) error, opts *RunOptions) ([]ScriptResult, error) {
+	if opts == nil {
+		opts = &RunOptions{}
 	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	i.mu.Lock()
+	i.outputs = make(map[string]*bytes.Buffer)
+	i.mu.Unlock()
 
+	scripts_to_run, err := i.assetScripts()
+	if err != nil {
+		if err := errorHandler("", err); err != nil {
+			return nil, err
+		}
+	}
 	for _, s := range scripts {
-		scripts_to_run = append(scripts_to_run, script{s, nil})
+		scripts_to_run = append(scripts_to_run, diskScript(s))
+	}
+
+	sess := &session{
+		opts:   opts,
+		load:   newLoader(i, opts),
+		shared: newNamespace(),
+	}
+	if opts.Parallel {
+		return i.runParallel(ctx, sess, scripts_to_run, errorHandler)
 	}
 
 	globals := starlark.StringDict{}
-	var err error
+	results := make([]ScriptResult, 0, len(scripts_to_run))
+	completed := make(map[string]bool, len(scripts_to_run))
 	for _, s := range scripts_to_run {
-		globals, err = run(s.name, s.src, globals)
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		res := s.prepare(completed)
+		if res == nil {
+			var exported *exports
+			res, exported = i.exec(ctx, sess, s, globals)
+			if res.Err != nil {
+				// A failed script resets the globals
+				globals = starlark.StringDict{}
+			} else {
+				sess.commit(globals, exported)
+			}
+		}
+		results = append(results, *res)
+		completed[s.name] = res.Err == nil && res.Skipped == ""
+		if res.Exit {
+			// On exit calls, no other scripts run
+			return results, &ExitError{Code: res.ExitCode}
+		}
+		if res.Err != nil {
+			if err := errorHandler(s.name, res.Err); err != nil {
+				return results, err
+			}
+		}
+	}
+	// The last script may have been cancelled too
+	return results, ctx.Err()
+}
+
+// prepare checks if the script should run. If it should not, the result explaining why is returned
+func (s *script) prepare(completed map[string]bool) *ScriptResult {
+	for _, dep := range s.dependsOn {
+		if !completed[dep] && s.skip == "" {
+			s.skip = "dependency '" + dep + "' did not complete"
+		}
+	}
+	var err error
+	if s.skip == "" {
+		err = s.applyPragma()
+	}
+	if s.skip == "" && err == nil {
+		return nil
+	}
+	now := time.Now()
+	return &ScriptResult{Name: s.name, Source: s.source, Start: now, End: now, Skipped: s.skip, Err: err}
+}
+
+var fileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       false,
+}
+
+// newThread creates a thread for a script, attaching the interpreter state that the modules need.
+// The options of the run come from the loader
+func (i *Interpreter) newThread(ctx context.Context, name string, load *loader) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name:  name,
+		Load:  load.Load,
+		Print: i.print,
+	}
+	modules.SetContext(thread, ctx)
+	modules.SetAssetLocker(thread, i.assets)
+	modules.SetPolicy(thread, i.Policy)
+	modules.SetInterceptors(thread, i.Interceptors)
+	modules.SetDryRun(thread, load.opts.DryRun)
+	modules.SetFS(thread, i.FS)
+	return thread
+}
+
+// assetScripts returns the scripts in the asset locker, in the order given by the manifest if there
+// is one. If the manifest is invalid no asset scripts are returned
+func (i *Interpreter) assetScripts() ([]script, error) {
+	assets := i.assets
+	if assets == nil {
+		return nil, nil
+	}
+	res := make([]script, 0, 1)
+	err := fs.WalkDir(assets, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			if err := errorHandler(s.name, err); err != nil {
-				return err
+			return err
+		}
+		compiled := strings.HasSuffix(path, CompiledExt)
+		if !strings.HasSuffix(path, ".eldr") && !strings.HasSuffix(path, ".eldritch") && !compiled {
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		buf, err := fs.ReadFile(assets, path)
+		if err != nil {
+			return nil
+		}
+		if compiled {
+			readAsset := func(name string) ([]byte, error) {
+				return fs.ReadFile(assets, name)
+			}
+			if readSource(readAsset, path) != nil {
+				// Runs with its source
+				return nil
 			}
+			res = append(res, script{name: path, compiled: buf, source: SourceAsset})
+			return nil
 		}
+		s := script{name: path, src: buf, source: SourceAsset}
+		if bytecode, err := fs.ReadFile(assets, CompiledName(path)); err == nil {
+			s.compiled = bytecode
+		}
+		res = append(res, s)
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("failed loading script from assets: %v", err)
 	}
-	return nil
+
+	m, err := loadManifest(assets)
+	if err == nil && m != nil {
+		res, err = m.apply(res)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ManifestName, err)
+	}
+	return res, nil
 }
 
-func run(name string, src interface{}, globals starlark.StringDict) (starlark.StringDict, error) {
-	thread := &starlark.Thread{Name: name}
-	opts := &syntax.FileOptions{
-		Set:             true,
-		While:           true,
-		TopLevelControl: true,
-		GlobalReassign:  true,
-		Recursion:       false,
-	}
-
-	libs := starlark.StringDict{
-		"assets":   &modules.Assets,
-		"crypto":   &modules.Crypto,
-		"file":     &modules.File,
-		"http":     &modules.Http,
-		"pivot":    &modules.Pivot,
-		"process":  &modules.Process,
-		"regex":    &modules.Regex,
-		"report":   &modules.Report,
-		"sys":      &modules.Sys,
-		"time":     &modules.Time,
-		"exit":     starlark.NewBuiltin("exit", exit),
-		"quit":     starlark.NewBuiltin("exit", quit),
-		"fallback": starlark.NewBuiltin("fallback", fallback),
+// exec runs a single script, returning its result and the globals it exports to the next scripts.
+// The given globals are not modified
+func (i *Interpreter) exec(ctx context.Context, sess *session, s script, globals starlark.StringDict) (*ScriptResult, *exports) {
+	if timeout := sess.opts.timeout(s); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	thread := i.newThread(ctx, s.name, sess.load)
+	exported := &exports{shared: starlark.StringDict{}}
+	thread.SetLocal(exportsKey, exported.shared)
+	output := &bytes.Buffer{}
+	thread.SetLocal(outputKey, output)
+	i.mu.Lock()
+	i.outputs[s.name] = output
+	i.mu.Unlock()
+	stop := context.AfterFunc(ctx, func() {
+		thread.Cancel(context.Cause(ctx).Error())
+	})
+	defer stop()
+
+	result := &ScriptResult{
+		Name:   s.name,
+		Source: s.source,
+		Start:  time.Now(),
+	}
+	defer func() {
+		result.End = time.Now()
+		result.Duration = result.End.Sub(result.Start)
+		i.mu.Lock()
+		result.Output = output.String()
+		i.mu.Unlock()
+	}()
+
+	libs := make(starlark.StringDict, len(i.modules)+len(globals)+1)
+	for k, v := range i.modules {
+		libs[k] = v
 	}
+	libs[sharedName] = sess.shared
 
 	// Add the globals into the environment
 	for k, v := range globals {
 		libs[k] = v
 	}
-	res, err := starlark.ExecFileOptions(opts, thread, name, src, libs)
-	if err != nil {
-		if e, ok := err.(*starlark.EvalError); ok {
-			// Check what the error message is, that is how we determined if we quit or exited
-			lines := strings.SplitN(e.Msg, ": ", 2)
-			if len(lines) < 2 {
-				return nil, err
-			}
-			if lines[1] == "user exit" {
-				// On exit calls, the interpreter also dies
-				os.Exit(int(exitCode))
-			} else if lines[1] == "user quit" {
-				// on quit calls, only the script exits, not an error
-				err = nil
-			} else {
-				return nil, err
+	res, warning, err := execProgram(thread, s.name, s.src, s.compiled, libs)
+	if warning != "" {
+		result.Warnings = append(result.Warnings, warning)
+	}
+	var exitErr *ExitError
+	if h := Halted(thread); errors.As(h, &exitErr) {
+		result.Exit = true
+		result.ExitCode = exitErr.Code
+	} else if h == ErrQuit {
+		// on quit calls, only the script exits, not an error
+		result.Quit = true
+	} else if err != nil && ctx.Err() != nil {
+		// The script was cancelled from the outside, report why instead of the cancellation
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.setErr(&TimeoutError{Script: s.name, Elapsed: time.Since(result.Start)})
+			// Keep where the script was when it ran out of time
+			var e *starlark.EvalError
+			if errors.As(err, &e) {
+				result.Backtrace = e.Backtrace()
 			}
 		} else {
-			return nil, err
+			result.setErr(ctx.Err())
 		}
+		return result, exported
+	} else if err != nil {
+		result.setErr(err)
+		return result, exported
 	}
 
-	if globals == nil {
-		globals = make(starlark.StringDict, len(res))
+	if !sess.opts.ExplicitExports {
+		exported.globals = make(starlark.StringDict, len(res))
 	}
-	// Update globals with the results of this script
 	for k, v := range res {
 		if strings.HasPrefix(k, "_") {
 			continue
 		}
-		globals[k] = v
+		if i.modules.Has(k) || k == sharedName {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("global '%s' shadows the gnome builtin of the same name", k))
+		}
+		if exported.globals != nil {
+			exported.globals[k] = v
+		}
+	}
+
+	result.Globals = make(map[string]interface{}, len(exported.globals)+len(exported.shared))
+	for _, values := range []starlark.StringDict{exported.globals, exported.shared} {
+		for k, v := range values {
+			// Functions and other starlark only values are not reported
+			if gv, err := modules.ToGolangValue(v); err == nil {
+				result.Globals[k] = gv
+			}
+		}
 	}
-	return globals, nil
+	return result, exported
 }
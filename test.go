@@ -0,0 +1,188 @@
+package gnome
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+// TestSuffix marks the scripts run by Test
+const TestSuffix = "_test.eldr"
+
+// TestPrefix marks the functions of a test script that are tests
+const TestPrefix = "test_"
+
+// TestResult describes a single test function, or a test script that failed before its tests ran
+type TestResult struct {
+	File string `json:"file"`
+	// Name is the name of the test function, empty if the script itself failed
+	Name     string        `json:"name,omitempty"`
+	Duration time.Duration `json:"duration"`
+	// Output is everything the test printed
+	Output    string `json:"output"`
+	Err       error  `json:"-"`
+	Backtrace string `json:"backtrace,omitempty"`
+}
+
+// Passed returns true if the test did not fail
+func (r TestResult) Passed() bool {
+	return r.Err == nil
+}
+
+// MarshalJSON encodes the result with the error as a string
+func (r TestResult) MarshalJSON() ([]byte, error) {
+	type result TestResult
+	var msg string
+	if r.Err != nil {
+		msg = r.Err.Error()
+	}
+	return json.Marshal(struct {
+		result
+		Error string `json:"error,omitempty"`
+	}{result(r), msg})
+}
+
+func (r *TestResult) setErr(err error) {
+	r.Err = err
+	var e *starlark.EvalError
+	if errors.As(err, &e) {
+		r.Backtrace = e.Backtrace()
+	}
+}
+
+// Test runs the test scripts found in paths. Directories are searched for scripts ending in
+// TestSuffix, files are run whatever their name. Each script is executed, then each of its global
+// functions starting with TestPrefix is called on its own thread. If match is not nil, only the
+// tests it returns true for are run
+func (i *Interpreter) Test(ctx context.Context, paths []string, match func(name string) bool) ([]TestResult, error) {
+	files, err := findTests(paths)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]TestResult, 0, len(files))
+	for _, f := range files {
+		results = append(results, i.testFile(ctx, f, match)...)
+	}
+	return results, nil
+}
+
+// findTests returns the test scripts in paths
+func findTests(paths []string) ([]string, error) {
+	res := make([]string, 0, len(paths))
+	for _, p := range paths {
+		st, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !st.IsDir() {
+			res = append(res, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, TestSuffix) {
+				res = append(res, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// testPredeclared returns the modules and builtins available to tests, which include the test
+// modules such as assert
+func (i *Interpreter) testPredeclared() starlark.StringDict {
+	libs := i.Predeclared()
+	for k, v := range testModules() {
+		libs[k] = v
+	}
+	return libs
+}
+
+// testFile runs the tests of a single script
+func (i *Interpreter) testFile(ctx context.Context, name string, match func(name string) bool) []TestResult {
+	load := newLoader(i, &i.Options)
+	setup := TestResult{File: name}
+	start := time.Now()
+	var globals starlark.StringDict
+	err := i.runTest(ctx, load, &setup, func(thread *starlark.Thread) error {
+		libs := i.testPredeclared()
+		libs[sharedName] = newNamespace()
+		var err error
+		globals, err = starlark.ExecFileOptions(fileOptions, thread, name, nil, libs)
+		if err == nil {
+			// Tests may not change the globals seen by the tests after them
+			globals.Freeze()
+		}
+		return err
+	})
+	setup.Duration = time.Since(start)
+	if err != nil {
+		return []TestResult{setup}
+	}
+
+	tests := make([]string, 0)
+	for k, v := range globals {
+		if _, ok := v.(starlark.Callable); ok && strings.HasPrefix(k, TestPrefix) && (match == nil || match(k)) {
+			tests = append(tests, k)
+		}
+	}
+	sort.Strings(tests)
+
+	res := make([]TestResult, 0, len(tests))
+	for _, t := range tests {
+		r := TestResult{File: name, Name: t}
+		start := time.Now()
+		i.runTest(ctx, load, &r, func(thread *starlark.Thread) error {
+			_, err := starlark.Call(thread, globals[t], nil, nil)
+			return err
+		})
+		r.Duration = time.Since(start)
+		res = append(res, r)
+	}
+	return res
+}
+
+// runTest calls fn on a new thread, recording its output and error in r
+func (i *Interpreter) runTest(ctx context.Context, load *loader, r *TestResult, fn func(*starlark.Thread) error) error {
+	if i.Options.ScriptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.Options.ScriptTimeout)
+		defer cancel()
+	}
+	thread := i.newThread(ctx, r.File, load)
+	var output strings.Builder
+	thread.Print = func(_ *starlark.Thread, msg string) {
+		output.WriteString(msg + "\n")
+	}
+	stop := context.AfterFunc(ctx, func() {
+		thread.Cancel(context.Cause(ctx).Error())
+	})
+	defer stop()
+
+	err := fn(thread)
+	if h := Halted(thread); h != nil {
+		err = fmt.Errorf("test stopped by %s", h)
+	} else if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	r.Output = output.String()
+	if err != nil {
+		r.setErr(err)
+	}
+	return err
+}
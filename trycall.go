@@ -0,0 +1,106 @@
+package gnome
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/nullmonk/gnome/modules"
+	"go.starlark.net/starlark"
+)
+
+/* Call a function, returning (value, None) if it succeeds or (None, error) if it fails */
+func tryCall(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: missing argument for fn", b.Name())
+	}
+	fn, ok := args[0].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("%s: for parameter fn: got %s, want callable", b.Name(), args[0].Type())
+	}
+	v, err := starlark.Call(thread, fn, args[1:], kwargs)
+	if err == nil {
+		return starlark.Tuple{v, starlark.None}, nil
+	}
+	// Stopping the script is not an error the script may catch
+	if Halted(thread) != nil || modules.Context(thread).Err() != nil {
+		return nil, err
+	}
+	return starlark.Tuple{starlark.None, newScriptError(fn, err)}, nil
+}
+
+// scriptError is the error value returned by try_call
+type scriptError struct {
+	// code is the name of the errno behind the error, such as "EACCES", empty if there is none
+	code     string
+	function string
+	path     string
+	message  string
+}
+
+func newScriptError(fn starlark.Callable, err error) *scriptError {
+	e := &scriptError{message: err.Error()}
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		e.message = evalErr.Msg
+	}
+
+	var callErr *modules.CallError
+	if errors.As(err, &callErr) {
+		e.function = callErr.Function
+		e.message = callErr.Err.Error()
+	} else if b, ok := fn.(*modules.Builtin); ok {
+		e.function = b.Name()
+	}
+
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	if errors.As(err, &pathErr) {
+		e.path = pathErr.Path
+	} else if errors.As(err, &linkErr) {
+		e.path = linkErr.Old
+	}
+
+	e.code = modules.ErrorCode(err)
+	return e
+}
+
+func (e *scriptError) String() string {
+	return e.message
+}
+
+func (e *scriptError) Type() string {
+	return "error"
+}
+
+func (e *scriptError) Freeze() {}
+
+func (e *scriptError) Truth() starlark.Bool {
+	return starlark.True
+}
+
+func (e *scriptError) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: error")
+}
+
+func (e *scriptError) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "code":
+		return starlark.String(e.code), nil
+	case "function":
+		return starlark.String(e.function), nil
+	case "path":
+		return starlark.String(e.path), nil
+	case "message":
+		return starlark.String(e.message), nil
+	}
+	return nil, nil
+}
+
+func (e *scriptError) AttrNames() []string {
+	names := []string{"code", "function", "path", "message"}
+	sort.Strings(names)
+	return names
+}